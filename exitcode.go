@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/neshmi/lg_remote/roap"
+)
+
+// Exit codes of lg_remote, so scripts and cron jobs can tell what went wrong
+const (
+	exitOK = 0
+	// exitError is anything not covered below, e.g. a bad flag or key name
+	exitError          = 1
+	exitPartialFailure = 2
+	exitTotalFailure   = 3
+	exitUnknownTV      = 4
+	exitConfigError    = 5
+	// exitAuthError is a TV without a pairing key or refusing it, see pair
+	exitAuthError = 6
+)
+
+// errorExitCode returns the exit code for an error that stopped a command
+// before it reached any TV
+func errorExitCode(err error) int {
+	var unknownTV *roap.UnknownTVError
+	var selectorErr *roap.SelectorError
+	var configErr *roap.ConfigError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &unknownTV), errors.As(err, &selectorErr), errors.Is(err, roap.ErrNoSelector):
+		return exitUnknownTV
+	case errors.As(err, &configErr):
+		return exitConfigError
+	case isAuthError(err):
+		return exitAuthError
+	}
+	return exitError
+}
+
+// resultsExitCode returns the exit code for a command that ran on TVs. When
+// every failure was an authorization failure the TVs need pairing, which is
+// reported as exitAuthError however many TVs failed.
+func resultsExitCode(results []roap.Result) int {
+	failed := roap.Failed(results)
+	if len(failed) == 0 {
+		return exitOK
+	}
+
+	auth := true
+	for _, result := range failed {
+		auth = auth && isAuthError(result.Err)
+	}
+	switch {
+	case auth:
+		return exitAuthError
+	case len(failed) == len(results):
+		return exitTotalFailure
+	}
+	return exitPartialFailure
+}
+
+// isAuthError reports whether err means the TV wouldn't authorize a session
+func isAuthError(err error) bool {
+	return errors.Is(err, roap.ErrNoPairingKey) || roap.IsUnauthorized(err)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/neshmi/lg_remote/roap"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExitCode(t *testing.T) {
+	tv1 := &roap.TV{Name: "TV-1"}
+	tv2 := &roap.TV{Name: "TV-2"}
+	unauthorized := &roap.ROAPError{Code: 401, Detail: "Unauthorized"}
+	unreachable := &roap.TransportError{Path: "/auth", Err: errors.New("connection refused")}
+
+	Convey("Given the results of a command", t, func() {
+		for _, test := range []struct {
+			name    string
+			results []roap.Result
+			want    int
+		}{
+			{"every TV succeeded", []roap.Result{{TV: tv1}, {TV: tv2}}, exitOK},
+			{"some TVs failed", []roap.Result{{TV: tv1}, {TV: tv2, Err: unreachable}}, exitPartialFailure},
+			{"every TV failed", []roap.Result{{TV: tv1, Err: unreachable}, {TV: tv2, Err: unauthorized}}, exitTotalFailure},
+			{"only authorization failed", []roap.Result{{TV: tv1}, {TV: tv2, Err: unauthorized}}, exitAuthError},
+			{"no TV has a pairing key", []roap.Result{{TV: tv1, Err: roap.ErrNoPairingKey}, {TV: tv2, Err: unauthorized}}, exitAuthError},
+		} {
+			Convey("It should exit with "+fmt.Sprint(test.want)+" when "+test.name, func() {
+				So(resultsExitCode(test.results), ShouldEqual, test.want)
+			})
+		}
+	})
+
+	Convey("Given an error that stopped a command", t, func() {
+		for _, test := range []struct {
+			name string
+			err  error
+			want int
+		}{
+			{"there is none", nil, exitOK},
+			{"a TV is unknown", &roap.UnknownTVError{Name: "TV-9"}, exitUnknownTV},
+			{"a selector is bad", &roap.SelectorError{Term: "loop", Err: errors.New("the group contains itself")}, exitUnknownTV},
+			{"no selector is given", roap.ErrNoSelector, exitUnknownTV},
+			{"the config is broken", &roap.ConfigError{Path: "tv_config.json", Err: errors.New("EOF")}, exitConfigError},
+			{"a TV refuses its key", unauthorized, exitAuthError},
+			{"anything else went wrong", errors.New("bad number of steps"), exitError},
+		} {
+			Convey("It should exit with "+fmt.Sprint(test.want)+" when "+test.name, func() {
+				So(errorExitCode(test.err), ShouldEqual, test.want)
+			})
+		}
+	})
+}
@@ -1,500 +1,771 @@
 package main
 
 import (
-	"encoding/json"
-	"encoding/xml"
+	"bufio"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"io"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/codegangsta/cli"
+	"github.com/neshmi/lg_remote/roap"
 )
 
-// Default port for LG TV API
-const Port string = "8080"
-
-// Default Base URI for LG TV
-const BaseURI string = "/roap/api"
-
-// TV record from JSON configuration file
-type TV struct {
-	Name           string `json:"name"`
-	IP             string `json:"ip"`
-	Key            string `json:"key"`
-	Current3DState string
-	Session        string
-}
-
-// TVConfig is a collection of TV records from the JSON configuration file
-type TVConfig struct {
-	TVs []TV
-}
-
-// GetAllTVs builds the TVConfig (and TVs) from the JSON file
-func GetAllTVs() []TV {
-	configPath := os.Getenv("LG_REMOTE_PATH")
-	configFile := os.Getenv("LG_REMOTE_CONFIG_FILE")
-	var filename string
-	var fileerr error
-	if configFile != "" && configPath != "" {
-		filename = filepath.Join(configPath, configFile)
-	} else {
-		filename, fileerr = filepath.Abs("./tv_config.json")
-	}
-	if fileerr != nil {
-		fmt.Println("Could not find TV Config file")
-	}
-
-	jsonFile, err := ioutil.ReadFile(filename)
-	if err != nil {
-		panic(err)
-	}
-
-	var tvConfig TVConfig
-
-	err = json.Unmarshal(jsonFile, &tvConfig)
-	if err != nil {
-		panic(err)
+func main() {
+	app := cli.NewApp()
+	app.Name = "LG Multi-screen Remote"
+	app.Usage = "Control a cluster of LG Smart TVs"
+	app.Version = "0.0.1"
+	ctx := context.Background()
+
+	var client *roap.Client
+	var configFile string
+	var config *roap.TVConfig
+	var registry *roap.Registry
+	var cluster *roap.Cluster
+	out := &printer{format: "text", out: os.Stdout}
+	exitCode := exitOK
+
+	// fail reports an error that stopped a command before it reached any TV
+	fail := func(err error) {
+		out.error(err)
+		exitCode = errorExitCode(err)
 	}
-	return tvConfig.TVs
-}
-
-//BuildURI returns the complete URI string
-func BuildURI(tv *TV, path string) string {
-	uri := "http://" + tv.IP + ":" + Port + BaseURI + path
-	return uri
-}
-
-//Check3D will check to see if a TV is currently in 3D mode
-func (tv *TV) Check3D() bool {
-	url := BuildURI(tv, "/data?target=is_3d")
-	type Result struct {
-		XMLName xml.Name `xml:"envelope"`
-		Is3D    string   `xml:"data>is3D"`
+	// report prints the results of a command and sets the exit code from them
+	report := func(c *cli.Context, results []roap.Result, synchronized bool) {
+		out.results(c.Command.FullName(), results, synchronized)
+		exitCode = resultsExitCode(results)
 	}
-	v := Result{}
 
-	resp, httperr := http.Get(url)
-	if httperr != nil {
-		panic(httperr)
+	app.Flags = []cli.Flag{
+		cli.DurationFlag{
+			Name:  "connect-timeout",
+			Usage: "give up connecting to a TV after this long (default from config, or 3s)",
+		},
+		cli.DurationFlag{
+			Name:  "read-timeout",
+			Usage: "give up waiting for a TV to answer after this long (default from config, or 5s)",
+		},
+		cli.DurationFlag{
+			Name:  "enable-3d-delay",
+			Usage: "pause between the 3D key and its confirmation (default from config, or 1s)",
+		},
+		cli.BoolFlag{
+			Name:  "no-cache",
+			Usage: "authorize every TV again instead of using cached sessions",
+		},
+		cli.IntFlag{
+			Name:  "concurrency",
+			Value: roap.DefaultConcurrency,
+			Usage: "how many TVs to work on at once",
+		},
+		cli.DurationFlag{
+			Name:  "tv-timeout",
+			Value: time.Minute,
+			Usage: "give up on a single TV after this long",
+		},
+		cli.StringFlag{
+			Name:  "on-failure",
+			Value: roap.BestEffort.String(),
+			Usage: "when some TVs fail: best-effort, all-or-nothing (roll the others back) or retry-failed",
+		},
+		cli.StringFlag{
+			Name:  "output",
+			Value: "text",
+			Usage: "text, json, or ndjson for one JSON object per line",
+		},
+		cli.IntFlag{
+			Name:  "retries",
+			Value: roap.DefaultRetries,
+			Usage: "how many times retry-failed tries a failed TV again",
+		},
 	}
 
-	body, readerr := ioutil.ReadAll(resp.Body)
-	if readerr == nil {
-		xml.Unmarshal(body, &v)
-		switch v.Is3D {
-		case "true":
-			tv.Current3DState = "on"
-		case "false":
-			tv.Current3DState = "off"
-		case "":
-			tv.Current3DState = "no-response"
-		default:
-			tv.Current3DState = "unknown"
+	app.Before = func(c *cli.Context) error {
+		var err error
+		out, err = newPrinter(c.String("output"))
+		if err != nil {
+			out = &printer{format: "text", out: os.Stdout}
+			return err
 		}
-		return true
-	}
-
-	return false
-}
-
-// SendXML will post XML to the TV and return teh response
-func (tv *TV) SendXML(data string, path string) (response *http.Response, err error) {
-	// or you can use []byte(`...`) and convert to Buffer later on
-	// build a new request, but not doing the POST yet
-	url := BuildURI(tv, path)
-	bodyReader := strings.NewReader(data)
-	resp, err := http.Post(url, "atom+xml", bodyReader)
-
-	return resp, err
-}
-
-// DisplayPairingKey causes the pairing key to be displayed on the passed TV object
-func (tv *TV) DisplayPairingKey() bool {
-	commandBody := `<!--?xml version=\"1.0\" encoding=\"utf-8\"?--><auth><type>AuthKeyReq</type></auth>`
-
-	type Result struct {
-		XMLName xml.Name `xml:"envelope"`
-		Success string   `xml:"ROAPErrorDetail"`
-	}
-
-	v := Result{}
-
-	resp, xmlerror := tv.SendXML(commandBody, "/auth")
-
-	if xmlerror != nil {
-		return false
+		configFile, err = roap.ConfigFile()
+		if err != nil {
+			return err
+		}
+		config, err = roap.LoadConfig(configFile)
+		if err != nil {
+			return err
+		}
+		if c.IsSet("connect-timeout") {
+			config.ConnectTimeout.Duration = c.Duration("connect-timeout")
+		}
+		if c.IsSet("read-timeout") {
+			config.ReadTimeout.Duration = c.Duration("read-timeout")
+		}
+		if c.IsSet("enable-3d-delay") {
+			config.Enable3DDelay.Duration = c.Duration("enable-3d-delay")
+			for i := range config.TVs {
+				config.TVs[i].Enable3DDelay.Duration = 0
+			}
+		}
+		policy, err := roap.ParsePolicy(c.String("on-failure"))
+		if err != nil {
+			return err
+		}
+		registry = roap.NewRegistry(config)
+		cluster = &roap.Cluster{
+			Registry:    registry,
+			Concurrency: c.Int("concurrency"),
+			Timeout:     c.Duration("tv-timeout"),
+			Policy:      policy,
+			Retries:     c.Int("retries"),
+		}
+		client = config.NewClient()
+		if c.Bool("no-cache") {
+			client.Cache = nil
+		}
+		return nil
 	}
 
-	body, readerr := ioutil.ReadAll(resp.Body)
-	if readerr == nil {
-		xml.Unmarshal(body, &v)
-	}
+	// execute applies op to every TV matched by the first argument, settles
+	// any failures according to --on-failure and prints the results. With
+	// --sync every TV is prepared first, by authorizing a session unless
+	// prepare is given, and op released on all TVs at once. Commands that
+	// can't be repeated pass a nil retry, those that can't be undone a nil undo.
+	execute := func(c *cli.Context, prepare func(context.Context, *roap.TV) error, op roap.Operation, retry roap.Operation, undo roap.Undo) {
+		selected, err := registry.Select(c.Args().First())
+		if err != nil {
+			fail(err)
+			return
+		}
 
-	return resp.StatusCode == 200 && v.Success == "OK"
-}
+		var results []roap.Result
+		synchronized := c.Bool("sync")
+		// records are streamed as each TV finishes, unless the failure policy
+		// may still change them
+		if cluster.Policy == roap.BestEffort || (cluster.Policy == roap.RetryFailed && retry == nil) || (cluster.Policy == roap.AllOrNothing && undo == nil) {
+			cluster.OnResult = out.stream(c.Command.FullName(), synchronized, nil)
+			defer func() { cluster.OnResult = nil }()
+		}
+		if synchronized {
+			if prepare == nil {
+				prepare = client.GetTVSession
+			}
+			results = cluster.RunSynchronized(ctx, selected, prepare, op)
+		} else {
+			results = cluster.Run(ctx, selected, op)
+		}
 
-// SendCommand to TV, 400 activates the 3D mode, 20 is the okay button
-func (tv *TV) SendCommand(command string) bool {
-	if tv.Session == "" {
-		if !tv.GetTVSession() {
-			fmt.Printf("%s could not get session\n", tv.Name)
-			return false
+		results = cluster.Recover(ctx, results, retry, undo)
+		report(c, results, synchronized)
+		if cluster.Policy == roap.AllOrNothing && undo == nil && len(roap.Failed(results)) > 0 {
+			out.note("This command can't be rolled back, the other TVs were left as they are")
+		}
+		if cluster.Policy == roap.RetryFailed && retry == nil && len(roap.Failed(results)) > 0 {
+			out.note("This command isn't retried, the failed TVs may have acted on it already")
 		}
 	}
 
-	commandBody := fmt.Sprintf(`<!--?xml version="1.0" encoding="utf-8"?--><command><name>HandleKeyInput</name><value>%s</value></command>`, command)
-
-	type Result struct {
-		XMLName xml.Name `xml:"envelope"`
-		Success string   `xml:"ROAPErrorDetail"`
+	// run is execute for commands that are safe to run again on failed TVs
+	run := func(c *cli.Context, prepare func(context.Context, *roap.TV) error, op roap.Operation, undo roap.Undo) {
+		execute(c, prepare, op, op, undo)
 	}
 
-	v := Result{}
-
-	resp, xmlerror := tv.SendXML(commandBody, "/command")
-	if xmlerror != nil {
-		return false
+	// runOnce is execute for commands that must not reach a TV twice, such
+	// as toggles, whatever --on-failure says
+	runOnce := func(c *cli.Context, op roap.Operation) {
+		execute(c, nil, op, nil, nil)
 	}
-	body, readerr := ioutil.ReadAll(resp.Body)
-	if readerr == nil {
-		xml.Unmarshal(body, &v)
-	}
-
-	return v.Success == "OK"
-}
 
-//Enable3D enables 3D mode if TV not in 3D mode
-func (tv *TV) Enable3D() bool {
-	if tv.Current3DState == "on" {
-		fmt.Printf("%s 3D Already Enabled\n", tv.Name)
-		return true
+	// runSequence runs a key sequence, only once if any of its keys toggles
+	runSequence := func(c *cli.Context, steps []roap.Step, op roap.Operation) {
+		for _, step := range steps {
+			if roap.IsToggle(step.Code) {
+				runOnce(c, op)
+				return
+			}
+		}
+		run(c, nil, op, nil)
 	}
-	var okResponse bool
 
-	enableResponse := tv.SendCommand("400")
-	//only send the second command if the first has sent successfuly
-	if enableResponse == true {
-		time.Sleep(1)
-		okResponse = tv.SendCommand("412")
-	}
+	// set3D switches 3D on or off, remembering where each TV started so it
+	// can be rolled back. When synchronized, the state of every TV is read
+	// while preparing so only the keys go out at the barrier.
+	set3D := func(c *cli.Context, want string) {
+		var mu sync.Mutex
+		plans := map[*roap.TV]*roap.Transition{}
+		transitions := map[*roap.TV]*roap.Transition{}
+
+		prepare := func(ctx context.Context, tv *roap.TV) error {
+			if err := client.GetTVSession(ctx, tv); err != nil {
+				return err
+			}
+			transition, err := client.Plan3D(ctx, tv)
+			mu.Lock()
+			plans[tv] = transition
+			mu.Unlock()
+			return err
+		}
 
-	if enableResponse && okResponse == true {
-		tv.Current3DState = "on"
-		fmt.Printf("%s 3D Enabled\n", tv.Name)
-		return true
-	}
-	return false
-}
+		op := func(ctx context.Context, tv *roap.TV) (string, error) {
+			// a plan is only good for the first attempt, retries read the
+			// state again
+			mu.Lock()
+			transition, planned := plans[tv]
+			delete(plans, tv)
+			mu.Unlock()
+
+			var err error
+			if planned && transition.Before != "" {
+				err = client.Apply3D(ctx, tv, transition, want)
+			} else {
+				transition, err = client.Set3D(ctx, tv, want)
+			}
+
+			mu.Lock()
+			if first, ok := transitions[tv]; ok {
+				first.Changed = first.Changed || transition.Changed
+			} else {
+				transitions[tv] = transition
+			}
+			mu.Unlock()
+			return describeTransition(transition), err
+		}
 
-//Disable3D disables 3D mode if currently in 3D
-func (tv *TV) Disable3D() bool {
-	if tv.Current3DState == "off" {
-		return true
-	}
-	disableResponse := tv.SendCommand("400")
-	if disableResponse == true {
-		tv.Current3DState = "off"
-		return true
-	}
-	return false
-}
+		// only TVs that were switched from a known state are switched back
+		undo := func(ctx context.Context, tv *roap.TV) (bool, error) {
+			mu.Lock()
+			transition := transitions[tv]
+			mu.Unlock()
+			if transition == nil || !transition.Changed || (transition.Before != "on" && transition.Before != "off") {
+				return false, nil
+			}
+			_, err := client.Set3D(ctx, tv, transition.Before)
+			return true, err
+		}
 
-//GetTVSession authorizes a Session
-func (tv *TV) GetTVSession() bool {
-	// abort if pairing key isn't present
-	if tv.Key == "" {
-		fmt.Printf("%s No Pairing Key, set key first\n", tv.Name)
-		return false
+		run(c, prepare, op, undo)
 	}
 
-	commandBody := fmt.Sprintf(`<!--?xml version="1.0" encoding="utf-8"?--><auth><type>AuthReq</type><value>%s</value></auth>`, tv.Key)
+	// query reads something from every TV matched by the first argument and
+	// prints what each one answered
+	query := func(c *cli.Context, action string, read func(context.Context, *roap.TV) (interface{}, error)) {
+		selected, err := registry.Select(c.Args().First())
+		if err != nil {
+			fail(err)
+			return
+		}
 
-	type Result struct {
-		XMLName   xml.Name `xml:"envelope"`
-		Success   string   `xml:"ROAPErrorDetail"`
-		SessionID string   `xml:"session"`
+		var mu sync.Mutex
+		data := map[*roap.TV]interface{}{}
+		cluster.OnResult = out.stream(action, false, func(r *record, result roap.Result) {
+			mu.Lock()
+			r.Data = data[result.TV]
+			mu.Unlock()
+		})
+		defer func() { cluster.OnResult = nil }()
+		results := cluster.Run(ctx, selected, func(ctx context.Context, tv *roap.TV) (string, error) {
+			value, err := read(ctx, tv)
+			if err != nil {
+				return "", err
+			}
+			mu.Lock()
+			data[tv] = value
+			mu.Unlock()
+			return "", nil
+		})
+		out.data(action, results, data)
+		exitCode = resultsExitCode(results)
 	}
 
-	v := Result{}
-
-	resp, senderror := tv.SendXML(commandBody, "/auth")
-	if senderror != nil {
-		// fmt.Println("Error sending command")
-		return false
+	// setVolume changes the volume of every TV matched by the first argument,
+	// remembering where each TV was so it can be rolled back
+	setVolume := func(c *cli.Context, change func(context.Context, *roap.TV) (*roap.VolumeChange, error)) {
+		var mu sync.Mutex
+		before := map[*roap.TV]int{}
+
+		run(c, nil, func(ctx context.Context, tv *roap.TV) (string, error) {
+			changed, err := change(ctx, tv)
+			if changed == nil {
+				return "", err
+			}
+			mu.Lock()
+			if _, ok := before[tv]; !ok {
+				before[tv] = changed.Before
+			}
+			mu.Unlock()
+			return fmt.Sprintf("Volume %d (was %d)", changed.After, changed.Before), err
+		}, func(ctx context.Context, tv *roap.TV) (bool, error) {
+			mu.Lock()
+			level, ok := before[tv]
+			mu.Unlock()
+			if !ok {
+				return false, nil
+			}
+			changed, err := client.SetVolume(ctx, tv, level)
+			return err != nil || changed.Before != changed.After, err
+		})
 	}
 
-	body, readerr := ioutil.ReadAll(resp.Body)
-	if readerr == nil && body != nil {
-		xml.Unmarshal(body, &v)
-		if v.Success == "OK" {
-			tv.Session = v.SessionID
-			return true
+	// adjustVolume turns the volume up or down, by direction times the steps
+	// given after the selector
+	adjustVolume := func(c *cli.Context, direction int) {
+		steps := 1
+		if c.Args().Get(1) != "" {
+			var err error
+			steps, err = strconv.Atoi(c.Args().Get(1))
+			if err != nil || steps < 1 {
+				fail(fmt.Errorf("bad number of steps %q", c.Args().Get(1)))
+				return
+			}
 		}
-		return false
+		// a retry aims for the level the first attempt did, so presses that
+		// went through aren't made again on top
+		var mu sync.Mutex
+		targets := map[*roap.TV]int{}
+		setVolume(c, func(ctx context.Context, tv *roap.TV) (*roap.VolumeChange, error) {
+			mu.Lock()
+			target, retried := targets[tv]
+			mu.Unlock()
+			if retried {
+				return client.SetVolume(ctx, tv, target)
+			}
+
+			changed, err := client.AdjustVolume(ctx, tv, direction*steps)
+			if changed != nil {
+				mu.Lock()
+				targets[tv] = changed.Want
+				mu.Unlock()
+			}
+			return changed, err
+		})
 	}
-	return false
-}
 
-//FindTvByName will return a TV from the TVConfig collection
-func FindTvByName(name string, tvs []TV) (tv *TV) {
-	for _, tvElement := range tvs {
-		if tvElement.Name == name {
-			return &tvElement
-		}
+	syncFlag := cli.BoolFlag{
+		Name:  "sync",
+		Usage: "authorize every TV first, then send to all of them at the same instant",
 	}
-	return &TV{}
-}
 
-func main() {
-	app := cli.NewApp()
-	app.Name = "LG Multi-screen Remote"
-	app.Usage = "Control a cluster of LG Smart TVs"
-	app.Version = "0.0.1"
-	tvs := GetAllTVs()
+	// without this the cli library exits with 3 for a mistyped command, which
+	// scripts would read as exitTotalFailure
+	app.CommandNotFound = func(c *cli.Context, command string) {
+		fail(fmt.Errorf("unknown command %q, run `help` for the list", command))
+	}
 
 	app.Commands = []cli.Command{
 		{
 			Name:    "enable-3D",
 			Aliases: []string{"e"},
-			Usage:   "enable [tv name or all]",
+			Usage:   "enable [tv, group, tag:name, pattern or all]",
+			Flags:   []cli.Flag{syncFlag},
 			Action: func(c *cli.Context) {
-				if c.Args().First() == "all" {
-
-					done := make(chan bool)
-
-					for _, tv := range tvs {
-						tv := tv
-						go func() {
-							fmt.Printf("Enabling: %s\n", tv.Name)
-							if tv.Enable3D() {
-								fmt.Printf("%s: Enabled 3D\n", tv.Name)
-							} else {
-								fmt.Printf("%s: Failed\n", tv.Name)
-							}
-							done <- true
-						}()
-					}
-
-					for _ = range tvs {
-						<-done
-					}
-
-				} else {
-					tv := FindTvByName(c.Args().First(), tvs)
-					if tv.Name == c.Args().First() {
-						fmt.Printf("Enabling: %s\n", tv.Name)
-						if tv.Enable3D() {
-							fmt.Printf("%s: Enabled 3D\n", tv.Name)
-						} else {
-							fmt.Printf("%s: Failed\n", tv.Name)
-						}
-					} else {
-						fmt.Printf("Couldn't find tv %s\n", c.Args().First())
-					}
-				}
+				set3D(c, "on")
 			},
 		},
 		{
 			Name:    "disable-3D",
 			Aliases: []string{"d"},
-			Usage:   "disable [tv name or all]",
+			Usage:   "disable [tv, group, tag:name, pattern or all]",
+			Flags:   []cli.Flag{syncFlag},
 			Action: func(c *cli.Context) {
-				if c.Args().First() == "all" {
-					done := make(chan bool)
-
-					for _, tv := range tvs {
-						tv := tv
-						go func() {
-							fmt.Printf("Disabling: %s\n", tv.Name)
-							if tv.Disable3D() {
-								fmt.Printf("%s: Disabled 3D\n", tv.Name)
-							} else {
-								fmt.Printf("%s: Failed\n", tv.Name)
-							}
-							done <- true
-						}()
-					}
-
-					for _ = range tvs {
-						<-done
-					}
-				} else {
-					tv := FindTvByName(c.Args().First(), tvs)
-					if tv.Name == c.Args().First() {
-						fmt.Printf("Disabling: %s\n", tv.Name)
-						if tv.Disable3D() {
-							fmt.Printf("%s: Disabled 3D\n", tv.Name)
-						} else {
-							fmt.Printf("%s: Failed\n", tv.Name)
-						}
-					} else {
-						fmt.Printf("Couldn't find tv %s\n", c.Args().First())
-					}
-				}
+				set3D(c, "off")
 			},
 		},
 		{
 			Name:    "send",
 			Aliases: []string{"s"},
-			Usage:   "send tv code",
+			Usage:   "send [tv, group, tag:name, pattern or all] [keys and delays...], e.g. send TV-1 3D 1s RIGHT OK, see `keys`",
+			Flags: []cli.Flag{
+				cli.DurationFlag{
+					Name:  "delay",
+					Value: 500 * time.Millisecond,
+					Usage: "pause between keys without an explicit delay",
+				},
+				syncFlag,
+			},
 			Action: func(c *cli.Context) {
-				if c.Args().First() == "all" {
-					done := make(chan bool)
-
-					for _, tv := range tvs {
-						tv := tv
-						go func() {
-							fmt.Printf("Sending command %s to: %s\n", c.Args()[1], tv.Name)
-							if tv.SendCommand(c.Args()[1]) {
-								fmt.Printf("Sent.\n")
-							} else {
-								fmt.Printf("Failed\n")
-							}
-							done <- true
-						}()
-					}
-
-					for _ = range tvs {
-						<-done
+				steps, err := roap.ParseSequence(c.Args().Tail(), c.Duration("delay"))
+				if err != nil {
+					fail(err)
+					return
+				}
+				runSequence(c, steps, func(ctx context.Context, tv *roap.TV) (string, error) {
+					return "Sent " + strings.Join(c.Args().Tail(), " "), client.SendSequence(ctx, tv, steps)
+				})
+			},
+		},
+		{
+			Name:    "macro",
+			Aliases: []string{"m"},
+			Usage:   "macro [tv, group, tag:name, pattern or all] [macro name], run without a name to list macros",
+			Flags: []cli.Flag{
+				cli.DurationFlag{
+					Name:  "delay",
+					Value: 500 * time.Millisecond,
+					Usage: "pause between keys without an explicit delay",
+				},
+				syncFlag,
+			},
+			Action: func(c *cli.Context) {
+				name := c.Args().Get(1)
+				if name == "" {
+					macros := map[string][]string{}
+					for _, name := range config.MacroNames() {
+						macros[name], _ = config.LookupMacro(name)
 					}
-				} else {
-					tv := FindTvByName(c.Args().First(), tvs)
-					if tv.Name == c.Args().First() {
-						fmt.Printf("Sending command %s to: %s\n", c.Args()[1], tv.Name)
-						if tv.SendCommand(c.Args()[1]) {
-							fmt.Printf("Sent.\n")
-						} else {
-							fmt.Printf("Failed\n")
+					out.value(macros, func(w io.Writer) {
+						for _, name := range config.MacroNames() {
+							fmt.Fprintf(w, "%-20s %s\n", name, strings.Join(macros[name], " "))
 						}
-					} else {
-						fmt.Printf("Couldn't find tv %s\n", c.Args().First())
-					}
+					})
+					return
+				}
+				tokens, err := config.LookupMacro(name)
+				if err != nil {
+					fail(err)
+					return
+				}
+				steps, err := roap.ParseSequence(tokens, c.Duration("delay"))
+				if err != nil {
+					fail(fmt.Errorf("%s: %w", name, err))
+					return
+				}
+				runSequence(c, steps, func(ctx context.Context, tv *roap.TV) (string, error) {
+					return "Ran " + name, client.SendSequence(ctx, tv, steps)
+				})
+			},
+		},
+		{
+			Name:  "keys",
+			Usage: "list the key names accepted by send",
+			Action: func(c *cli.Context) {
+				keys := map[string]int{}
+				for _, key := range roap.Keys {
+					keys[key.Name] = key.Code
 				}
+				out.value(keys, func(w io.Writer) {
+					for _, key := range roap.Keys {
+						fmt.Fprintf(w, "%-20s %d\n", key.Name, key.Code)
+					}
+				})
 			},
 		},
 		{
 			Name:    "query-3D-state",
 			Aliases: []string{"q"},
-			Usage:   "query [tv name or all]",
+			Usage:   "query [tv, group, tag:name, pattern or all]",
 			Action: func(c *cli.Context) {
-				if c.Args().First() == "all" {
-					done := make(chan bool)
-
-					for _, tv := range tvs {
-						tv := tv
-						go func() {
-							fmt.Printf("Checking: %s\n", tv.Name)
-							if tv.Check3D() {
-								fmt.Printf("%s 3D State: %s\n", tv.Name, tv.Current3DState)
-							} else {
-								fmt.Printf("%s: Failed\n", tv.Name)
-							}
-							done <- true
-						}()
-					}
+				run(c, nil, func(ctx context.Context, tv *roap.TV) (string, error) {
+					err := client.Check3D(ctx, tv)
+					return "3D State: " + tv.Current3DState, err
+				}, nil)
+			},
+		},
+		{
+			Name:  "status",
+			Usage: "status [tv, group, tag:name, pattern or all, the default], check reachability, pairing, 3D, channel and volume",
+			Action: func(c *cli.Context) {
+				selector := c.Args().First()
+				if selector == "" {
+					selector = "all"
+				}
+				selected, err := registry.Select(selector)
+				if err != nil {
+					fail(err)
+					return
+				}
 
-					for _ = range tvs {
-						<-done
-					}
-				} else {
-					tv := FindTvByName(c.Args().First(), tvs)
-					if tv.Name == c.Args().First() {
-						fmt.Printf("Checking: %s\n", tv.Name)
-						if tv.Check3D() {
-							fmt.Printf("%s 3D State: %s\n", tv.Name, tv.Current3DState)
-						} else {
-							fmt.Printf("%s: Failed\n", tv.Name)
-						}
-					} else {
-						fmt.Printf("Couldn't find tv %s\n", c.Args().First())
-					}
+				var mu sync.Mutex
+				statuses := map[*roap.TV]*roap.Status{}
+				cluster.OnResult = out.stream(c.Command.FullName(), false, func(r *record, result roap.Result) {
+					mu.Lock()
+					r.Status = newStatusRecord(statuses[result.TV])
+					mu.Unlock()
+				})
+				defer func() { cluster.OnResult = nil }()
+				results := cluster.Run(ctx, selected, func(ctx context.Context, tv *roap.TV) (string, error) {
+					status, err := client.Status(ctx, tv)
+					mu.Lock()
+					statuses[tv] = status
+					mu.Unlock()
+					return "", err
+				})
+				out.statuses(c.Command.FullName(), results, statuses)
+				exitCode = resultsExitCode(results)
+			},
+		},
+		{
+			Name:  "query",
+			Usage: "query [tv, group, tag:name, pattern or all] [target], read " + strings.Join(roap.DataTargets, ", ") + " without sending keys",
+			Action: func(c *cli.Context) {
+				target := c.Args().Get(1)
+				known := false
+				for _, t := range roap.DataTargets {
+					known = known || t == target
+				}
+				if !known {
+					fail(&roap.UnknownTargetError{Target: target})
+					return
 				}
+				query(c, c.Command.FullName()+" "+target, func(ctx context.Context, tv *roap.TV) (interface{}, error) {
+					return client.Query(ctx, tv, target)
+				})
 			},
 		},
 		{
-			Name:    "display-pairing-key",
-			Aliases: []string{"r"},
-			Usage:   "pair [tv name or all]",
+			Name:  "type",
+			Usage: "type [tv, group, tag:name, pattern or all] [text], fill the field the on-screen keyboard is open on, e.g. type all \"cave viewer\"",
+			Flags: []cli.Flag{syncFlag},
 			Action: func(c *cli.Context) {
-				if c.Args().First() == "all" {
-					done := make(chan bool)
-
-					for _, tv := range tvs {
-						tv := tv
-						go func() {
-							fmt.Printf("Display key for: %s\n", tv.Name)
-							if tv.DisplayPairingKey() {
-								fmt.Printf("Displaying...\n")
-							} else {
-								fmt.Printf("%s: Failed\n", tv.Name)
-							}
-							done <- true
-						}()
-					}
+				text := strings.Join(c.Args().Tail(), " ")
+				if text == "" {
+					fail(roap.ErrNoText)
+					return
+				}
+				runOnce(c, func(ctx context.Context, tv *roap.TV) (string, error) {
+					return fmt.Sprintf("Typed %q", text), client.TypeText(ctx, tv, text)
+				})
+			},
+		},
+		{
+			Name:  "channel",
+			Usage: "channel [tv, group, tag:name, pattern or all] [major[-minor]], tune to a channel, or show the current one without a number",
+			Action: func(c *cli.Context) {
+				number := c.Args().Get(1)
+				if number == "" {
+					query(c, c.Command.FullName(), func(ctx context.Context, tv *roap.TV) (interface{}, error) {
+						return client.CurrentChannel(ctx, tv)
+					})
+					return
+				}
 
-					for _ = range tvs {
-						<-done
+				major, minor, err := roap.ParseChannelNumber(number)
+				if err != nil {
+					fail(err)
+					return
+				}
+				run(c, nil, func(ctx context.Context, tv *roap.TV) (string, error) {
+					ch, err := client.ChangeChannel(ctx, tv, major, minor)
+					if err != nil {
+						return "", err
 					}
-				} else {
-					tv := FindTvByName(c.Args().First(), tvs)
-					if tv.Name == c.Args().First() {
-						fmt.Printf("Display key for: %s\n", tv.Name)
-						if tv.DisplayPairingKey() {
-							fmt.Printf("Displaying...\n")
-						} else {
-							fmt.Printf("%s: Failed\n", tv.Name)
+					return "Tuned to " + ch.String(), nil
+				}, nil)
+			},
+		},
+		{
+			Name:  "channels",
+			Usage: "channels [tv, group, tag:name, pattern or all], list the channels each TV has tuned",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "refresh",
+					Usage: "ask the TV again instead of using the cached list",
+				},
+			},
+			Action: func(c *cli.Context) {
+				query(c, c.Command.FullName(), func(ctx context.Context, tv *roap.TV) (interface{}, error) {
+					return client.Channels(ctx, tv, c.Bool("refresh"))
+				})
+			},
+		},
+		{
+			Name:  "volume",
+			Usage: "volume get|set|up|down|mute [tv, group, tag:name, pattern or all] ...",
+			Subcommands: []cli.Command{
+				{
+					Name:  "get",
+					Usage: "get [tv, group, tag:name, pattern or all]",
+					Action: func(c *cli.Context) {
+						query(c, c.Command.FullName(), func(ctx context.Context, tv *roap.TV) (interface{}, error) {
+							return client.Volume(ctx, tv)
+						})
+					},
+				},
+				{
+					Name:  "set",
+					Usage: "set [tv, group, tag:name, pattern or all] [level], bring every TV to the same level",
+					Action: func(c *cli.Context) {
+						level, err := strconv.Atoi(c.Args().Get(1))
+						if err != nil {
+							fail(fmt.Errorf("bad volume level %q", c.Args().Get(1)))
+							return
 						}
-					} else {
-						fmt.Printf("Couldn't find tv %s\n", c.Args().First())
-					}
+						setVolume(c, func(ctx context.Context, tv *roap.TV) (*roap.VolumeChange, error) {
+							return client.SetVolume(ctx, tv, level)
+						})
+					},
+				},
+				{
+					Name:  "up",
+					Usage: "up [tv, group, tag:name, pattern or all] [steps, default 1]",
+					Action: func(c *cli.Context) {
+						adjustVolume(c, 1)
+					},
+				},
+				{
+					Name:  "down",
+					Usage: "down [tv, group, tag:name, pattern or all] [steps, default 1]",
+					Action: func(c *cli.Context) {
+						adjustVolume(c, -1)
+					},
+				},
+				{
+					Name:  "mute",
+					Usage: "mute [tv, group, tag:name, pattern or all] [on or off, default on]",
+					Action: func(c *cli.Context) {
+						mute := true
+						switch c.Args().Get(1) {
+						case "", "on":
+						case "off":
+							mute = false
+						default:
+							fail(fmt.Errorf("bad mute setting %q, expected on or off", c.Args().Get(1)))
+							return
+						}
+
+						var mu sync.Mutex
+						was := map[*roap.TV]bool{}
+						run(c, nil, func(ctx context.Context, tv *roap.TV) (string, error) {
+							before, err := client.SetMute(ctx, tv, mute)
+							mu.Lock()
+							was[tv] = before
+							mu.Unlock()
+							if err != nil {
+								return "", err
+							}
+							if mute {
+								return "Muted", nil
+							}
+							return "Unmuted", nil
+						}, func(ctx context.Context, tv *roap.TV) (bool, error) {
+							mu.Lock()
+							before, ok := was[tv]
+							mu.Unlock()
+							if !ok || before == mute {
+								return false, nil
+							}
+							_, err := client.SetMute(ctx, tv, before)
+							return true, err
+						})
+					},
+				},
+			},
+		},
+		{
+			Name:  "apps",
+			Usage: "apps list|launch|terminate [tv, group, tag:name, pattern or all] ...",
+			Subcommands: []cli.Command{
+				{
+					Name:  "list",
+					Usage: "list [tv, group, tag:name, pattern or all]",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "refresh",
+							Usage: "ask the TV again instead of using the cached list",
+						},
+					},
+					Action: func(c *cli.Context) {
+						query(c, c.Command.FullName(), func(ctx context.Context, tv *roap.TV) (interface{}, error) {
+							return client.Apps(ctx, tv, c.Bool("refresh"))
+						})
+					},
+				},
+				{
+					Name:  "launch",
+					Usage: "launch [tv, group, tag:name, pattern or all] [app name or auid], names may be abbreviated",
+					Action: func(c *cli.Context) {
+						name := strings.Join(c.Args().Tail(), " ")
+						// not undone under all-or-nothing, the TV doesn't say
+						// whether the app was already running before
+						run(c, nil, func(ctx context.Context, tv *roap.TV) (string, error) {
+							app, err := client.LaunchApp(ctx, tv, name)
+							if err != nil {
+								return "", err
+							}
+							return "Launched " + app.Name, nil
+						}, nil)
+					},
+				},
+				{
+					Name:  "terminate",
+					Usage: "terminate [tv, group, tag:name, pattern or all] [app name or auid]",
+					Action: func(c *cli.Context) {
+						name := strings.Join(c.Args().Tail(), " ")
+						run(c, nil, func(ctx context.Context, tv *roap.TV) (string, error) {
+							app, err := client.TerminateApp(ctx, tv, name)
+							if err != nil {
+								return "", err
+							}
+							return "Terminated " + app.Name, nil
+						}, nil)
+					},
+				},
+			},
+		},
+		{
+			Name:    "display-pairing-key",
+			Aliases: []string{"r"},
+			Usage:   "display-pairing-key [tv, group, tag:name, pattern or all]",
+			Action: func(c *cli.Context) {
+				runOnce(c, func(ctx context.Context, tv *roap.TV) (string, error) {
+					return "Displaying...", client.DisplayPairingKey(ctx, tv)
+				})
+			},
+		},
+		{
+			Name:  "pair",
+			Usage: "pair [tv, group, tag:name, pattern or all], then enter the key shown on each TV",
+			Action: func(c *cli.Context) {
+				selected, err := registry.Select(c.Args().First())
+				if err != nil {
+					fail(err)
+					return
 				}
+
+				// one TV at a time and without a deadline, someone has to walk
+				// over and read each key off the screen
+				in := bufio.NewReader(os.Stdin)
+				pairing := &roap.Cluster{Registry: registry, Concurrency: 1}
+				report(c, pairing.Run(ctx, selected, func(ctx context.Context, tv *roap.TV) (string, error) {
+					return "Paired", pairTV(ctx, client, tv, configFile, in)
+				}), false)
 			},
 		},
 		{
 			Name:    "power-off",
 			Aliases: []string{"p"},
-			Usage:   "pair [tv name or all]",
+			Usage:   "power-off [tv, group, tag:name, pattern or all]",
+			Flags:   []cli.Flag{syncFlag},
 			Action: func(c *cli.Context) {
-				if c.Args().First() == "all" {
-					done := make(chan bool)
-
-					for _, tv := range tvs {
-						tv := tv
-						go func() {
-							fmt.Printf("Powering off: %s\n", tv.Name)
-							if tv.SendCommand("1") {
-								fmt.Printf("Powered off %s\n", tv.Name)
-							} else {
-								fmt.Printf("%s: Failed\n", tv.Name)
-							}
-							done <- true
-						}()
-					}
-
-					for _ = range tvs {
-						<-done
-					}
-				} else {
-					tv := FindTvByName(c.Args().First(), tvs)
-					if tv.Name == c.Args().First() {
-						fmt.Printf("Powering off: %s\n", tv.Name)
-						if tv.SendCommand("1") {
-							fmt.Printf("Powered off %s", tv.Name)
-						} else {
-							fmt.Printf("%s: Failed\n", tv.Name)
-						}
-					} else {
-						fmt.Printf("Couldn't find tv %s\n", c.Args().First())
-					}
-				}
+				runOnce(c, func(ctx context.Context, tv *roap.TV) (string, error) {
+					return "Powered off", client.SendCommand(ctx, tv, "1")
+				})
 			},
 		},
 	}
 
-	app.Run(os.Args)
+	if err := app.Run(os.Args); err != nil {
+		out.error(err)
+		os.Exit(errorExitCode(err))
+	}
+	os.Exit(exitCode)
+}
+
+// describeTransition reports the outcome of a 3D change on one TV, including
+// any disagreement between the cached state and what the TV reported
+func describeTransition(transition *roap.Transition) string {
+	var outcome string
+	if transition.Changed {
+		outcome = fmt.Sprintf("3D %s (was %s)", transition.After, transition.Before)
+	} else {
+		outcome = fmt.Sprintf("3D already %s", transition.After)
+	}
+	if transition.Drifted() {
+		outcome += fmt.Sprintf(", cache said %s", transition.Cached)
+	}
+	return outcome
 }
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/neshmi/lg_remote/roap"
+)
+
+// record is the JSON form of the result of a command on one TV
+type record struct {
+	Type       string       `json:"type"`
+	Name       string       `json:"name"`
+	IP         string       `json:"ip"`
+	Action     string       `json:"action"`
+	Success    bool         `json:"success"`
+	Outcome    string       `json:"outcome,omitempty"`
+	Error      *errorRecord `json:"error,omitempty"`
+	State      string       `json:"state,omitempty"`
+	LatencyMS  float64      `json:"latency_ms"`
+	SkewMS     *float64     `json:"skew_ms,omitempty"`
+	Retries    int          `json:"retries,omitempty"`
+	RolledBack bool         `json:"rolled_back,omitempty"`
+	// RollbackError is set when undoing the action on the TV failed
+	RollbackError *errorRecord `json:"rollback_error,omitempty"`
+	// Status is the health report of the status command
+	Status *statusRecord `json:"status,omitempty"`
+	// Data is what the TV answered to the query command
+	Data interface{} `json:"data,omitempty"`
+}
+
+// statusRecord is the JSON form of a roap.Status
+type statusRecord struct {
+	Reachable  bool             `json:"reachable"`
+	LatencyMS  float64          `json:"tcp_latency_ms"`
+	Authorized bool             `json:"authorized"`
+	State3D    string           `json:"3d_state,omitempty"`
+	Channel    *roap.Channel    `json:"channel,omitempty"`
+	Volume     *roap.VolumeInfo `json:"volume,omitempty"`
+}
+
+// errorRecord describes a failure, with the ROAPError code when the TV sent one
+type errorRecord struct {
+	Code      string `json:"code"`
+	ROAPError int    `json:"roap_error,omitempty"`
+	Detail    string `json:"detail"`
+}
+
+// summary is the aggregate that follows the records of a command
+type summary struct {
+	Type       string   `json:"type"`
+	Action     string   `json:"action"`
+	Total      int      `json:"total"`
+	Succeeded  int      `json:"succeeded"`
+	Failed     int      `json:"failed"`
+	RolledBack int      `json:"rolled_back"`
+	SkewMS     *float64 `json:"skew_ms,omitempty"`
+
+	// sent counts the TVs a synchronized command went out to, spread is the
+	// time between the first and the last
+	sent   int
+	spread time.Duration
+}
+
+// printer writes what commands have to say as text for people, or as JSON or
+// newline delimited JSON for scripts, selected with --output
+type printer struct {
+	format string
+	out    io.Writer
+	// streamed is set once stream has written the records of a command, so
+	// only its summary is left to write
+	streamed bool
+}
+
+// newPrinter returns a printer for format, "text", "json" or "ndjson"
+func newPrinter(format string) (*printer, error) {
+	switch format {
+	case "text", "json", "ndjson":
+		return &printer{format: format, out: os.Stdout}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, expected text, json or ndjson", format)
+}
+
+// structured reports whether the output is meant for scripts
+func (p *printer) structured() bool {
+	return p.format != "text"
+}
+
+// encode writes v as a single JSON document or line
+func (p *printer) encode(v interface{}) {
+	encoder := json.NewEncoder(p.out)
+	if p.format == "json" {
+		encoder.SetIndent("", "  ")
+	}
+	encoder.Encode(v)
+}
+
+// value writes v for scripts, or calls text to describe it to people
+func (p *printer) value(v interface{}, text func(out io.Writer)) {
+	if p.structured() {
+		p.encode(v)
+		return
+	}
+	text(p.out)
+}
+
+// error reports an error that stopped a command before it reached any TV
+func (p *printer) error(err error) {
+	if p.structured() {
+		p.encode(struct {
+			Type  string       `json:"type"`
+			Error *errorRecord `json:"error"`
+		}{"error", newErrorRecord(err)})
+		return
+	}
+	fmt.Fprintln(p.out, err)
+}
+
+// note tells people something about the results, out of the way of scripts
+func (p *printer) note(format string, args ...interface{}) {
+	out := p.out
+	if p.structured() {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, format+"\n", args...)
+}
+
+// results prints the outcome of action on each TV in configuration order,
+// then the counts. For synchronized runs it adds when each TV's command went
+// out after the barrier and the spread between the TVs.
+func (p *printer) results(action string, results []roap.Result, synchronized bool) {
+	records, total := newRecords(action, results, synchronized)
+	if p.structured() {
+		p.records(records, total)
+		return
+	}
+	p.text(results, total, synchronized)
+}
+
+// statuses prints the health of each TV as a table, or as records with a
+// status for scripts
+func (p *printer) statuses(action string, results []roap.Result, statuses map[*roap.TV]*roap.Status) {
+	records, total := newRecords(action, results, false)
+	for i, result := range results {
+		records[i].Status = newStatusRecord(statuses[result.TV])
+	}
+	if p.structured() {
+		p.records(records, total)
+		return
+	}
+
+	table := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "TV\tIP\tREACHABLE\tLATENCY\tAUTHORIZED\t3D\tCHANNEL\tINPUT\tVOLUME\tERROR")
+	for _, record := range records {
+		status := record.Status
+		if status == nil {
+			status = &statusRecord{}
+		}
+		latency, channel, input, volume, problem := "-", "-", "-", "-", ""
+		if status.Reachable {
+			latency = fmt.Sprintf("%vms", status.LatencyMS)
+		}
+		if status.Channel != nil {
+			channel = strings.TrimSpace(status.Channel.Number() + " " + status.Channel.Name)
+			input = status.Channel.InputSourceName
+		}
+		if status.Volume != nil {
+			volume = fmt.Sprintf("%d", status.Volume.Level)
+			if status.Volume.Mute {
+				volume += " (muted)"
+			}
+		}
+		if record.Error != nil {
+			problem = record.Error.Detail
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", record.Name, record.IP,
+			yesNo(status.Reachable), latency, yesNo(status.Authorized), orDash(status.State3D),
+			channel, orDash(input), volume, problem)
+	}
+	table.Flush()
+	fmt.Fprintf(p.out, "%d succeeded, %d failed\n", total.Succeeded, total.Failed)
+}
+
+// data prints what each TV answered to a query, lists one entry per line
+func (p *printer) data(action string, results []roap.Result, data map[*roap.TV]interface{}) {
+	records, total := newRecords(action, results, false)
+	for i, result := range results {
+		records[i].Data = data[result.TV]
+	}
+	if p.structured() {
+		p.records(records, total)
+		return
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(p.out, "%s: Failed: %v\n", result.TV.Name, result.Err)
+			continue
+		}
+		value := reflect.ValueOf(data[result.TV])
+		if value.Kind() != reflect.Slice {
+			fmt.Fprintf(p.out, "%s: %v\n", result.TV.Name, data[result.TV])
+			continue
+		}
+		fmt.Fprintf(p.out, "%s: %d entries\n", result.TV.Name, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			fmt.Fprintf(p.out, "    %v\n", value.Index(i).Interface())
+		}
+	}
+	fmt.Fprintf(p.out, "%d succeeded, %d failed\n", total.Succeeded, total.Failed)
+}
+
+// stream returns a function writing the ndjson record of a result as soon
+// as its TV finishes, for roap.Cluster.OnResult, and nil for any other format.
+// fill, when given, adds what the command learned about the TV to the record.
+func (p *printer) stream(action string, synchronized bool, fill func(*record, roap.Result)) func(roap.Result) {
+	if p.format != "ndjson" {
+		return nil
+	}
+	p.streamed = true
+	return func(result roap.Result) {
+		record := newRecord(action, result, synchronized)
+		if fill != nil {
+			fill(&record, result)
+		}
+		p.encode(record)
+	}
+}
+
+// records writes records and their summary as JSON or ndjson, leaving out
+// records that were streamed already
+func (p *printer) records(records []record, total summary) {
+	if p.format == "ndjson" {
+		if !p.streamed {
+			for _, record := range records {
+				p.encode(record)
+			}
+		}
+		p.streamed = false
+		p.encode(total)
+		return
+	}
+	p.encode(struct {
+		Results []record `json:"results"`
+		Summary summary  `json:"summary"`
+	}{records, total})
+}
+
+// stateActions are the actions whose records carry the TV's 3D state in
+// State, for any other it would say nothing about what the action did
+var stateActions = map[string]bool{
+	"enable-3D":      true,
+	"disable-3D":     true,
+	"query-3D-state": true,
+}
+
+// newRecords describes results for scripts and counts them up
+func newRecords(action string, results []roap.Result, synchronized bool) ([]record, summary) {
+	records := make([]record, len(results))
+	total := summary{Type: "summary", Action: action, Total: len(results)}
+	var first, last time.Duration
+
+	for i, result := range results {
+		records[i] = newRecord(action, result, synchronized)
+		if result.Err == nil {
+			total.Succeeded++
+		} else {
+			total.Failed++
+		}
+		if result.RolledBack {
+			total.RolledBack++
+		}
+
+		if synchronized && result.Sent {
+			if total.sent == 0 || result.Skew < first {
+				first = result.Skew
+			}
+			if total.sent == 0 || result.Skew > last {
+				last = result.Skew
+			}
+			total.sent++
+		}
+	}
+	if total.sent > 0 {
+		total.spread = last - first
+		spread := milliseconds(total.spread)
+		total.SkewMS = &spread
+	}
+
+	return records, total
+}
+
+// newRecord describes the result of action on one TV for scripts
+func newRecord(action string, result roap.Result, synchronized bool) record {
+	record := record{
+		Type:          "result",
+		Name:          result.TV.Name,
+		IP:            result.TV.IP,
+		Action:        action,
+		Success:       result.Err == nil,
+		Outcome:       result.Outcome,
+		Error:         newErrorRecord(result.Err),
+		LatencyMS:     milliseconds(result.Duration),
+		Retries:       result.Retries,
+		RolledBack:    result.RolledBack,
+		RollbackError: newErrorRecord(result.RollbackErr),
+	}
+	if stateActions[action] {
+		record.State = result.TV.Current3DState
+	}
+	if synchronized && result.Sent {
+		skew := milliseconds(result.Skew)
+		record.SkewMS = &skew
+	}
+	return record
+}
+
+// text prints results for people
+func (p *printer) text(results []roap.Result, total summary, synchronized bool) {
+	for _, result := range results {
+		line := result.Outcome
+		if result.Err != nil {
+			line = fmt.Sprintf("Failed: %v", result.Err)
+		}
+		if result.Retries > 0 {
+			line += fmt.Sprintf(" (after %d retries)", result.Retries)
+		}
+		if synchronized && result.Sent {
+			line += fmt.Sprintf(" (sent +%v)", result.Skew)
+		}
+		fmt.Fprintf(p.out, "%s: %s\n", result.TV.Name, line)
+	}
+
+	fmt.Fprintf(p.out, "%d succeeded, %d failed\n", total.Succeeded, total.Failed)
+	if total.sent > 0 {
+		fmt.Fprintf(p.out, "Send skew across %d TVs: %v\n", total.sent, total.spread)
+	}
+
+	var rolledBack []string
+	for _, result := range roap.RolledBack(results) {
+		rolledBack = append(rolledBack, result.TV.Name)
+	}
+	if len(rolledBack) > 0 {
+		fmt.Fprintf(p.out, "Rolled back: %s\n", strings.Join(rolledBack, ", "))
+	}
+	for _, result := range results {
+		if result.RollbackErr != nil {
+			fmt.Fprintf(p.out, "%s: Rollback failed: %v\n", result.TV.Name, result.RollbackErr)
+		}
+	}
+}
+
+// newErrorRecord describes err for scripts, nil when there is no error
+func newErrorRecord(err error) *errorRecord {
+	if err == nil {
+		return nil
+	}
+	record := &errorRecord{Code: roap.ErrorCode(err), Detail: err.Error()}
+	var roapErr *roap.ROAPError
+	if errors.As(err, &roapErr) {
+		record.ROAPError = roapErr.Code
+		record.Detail = roapErr.Detail
+	}
+	return record
+}
+
+// newStatusRecord describes the health of a TV for scripts, nil when the
+// status command didn't get that far
+func newStatusRecord(status *roap.Status) *statusRecord {
+	if status == nil {
+		return nil
+	}
+	return &statusRecord{
+		Reachable:  status.Reachable,
+		LatencyMS:  milliseconds(status.Latency),
+		Authorized: status.Authorized,
+		State3D:    status.State3D,
+		Channel:    status.Channel,
+		Volume:     status.Volume,
+	}
+}
+
+// yesNo describes a check for the status table
+func yesNo(ok bool) string {
+	if ok {
+		return "yes"
+	}
+	return "no"
+}
+
+// orDash fills in an empty cell of the status table
+func orDash(text string) string {
+	if text == "" {
+		return "-"
+	}
+	return text
+}
+
+// milliseconds returns d in milliseconds, to the microsecond
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
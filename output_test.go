@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neshmi/lg_remote/roap"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// decodeLines decodes each line of ndjson output
+func decodeLines(output string) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var v map[string]interface{}
+		json.Unmarshal([]byte(line), &v)
+		lines = append(lines, v)
+	}
+	return lines
+}
+
+func TestOutput(t *testing.T) {
+	Convey("Given the results of a command on three TVs", t, func() {
+		tv1 := &roap.TV{Name: "TV-1", IP: "192.168.1.100", Current3DState: "on"}
+		tv2 := &roap.TV{Name: "TV-2", IP: "192.168.1.101", Current3DState: "off"}
+		tv3 := &roap.TV{Name: "TV-3", IP: "192.168.1.102", Current3DState: "on"}
+		results := []roap.Result{
+			{TV: tv1, Outcome: "3D on", Duration: 1500 * time.Microsecond, Sent: true, Skew: 2 * time.Millisecond},
+			{TV: tv2, Err: &roap.ROAPError{Code: 401, Detail: "Unauthorized"}, Duration: time.Millisecond},
+			{TV: tv3, Outcome: "3D on", Duration: time.Millisecond, Sent: true, Skew: 5 * time.Millisecond, RolledBack: true},
+		}
+
+		Convey("It should describe each TV and count them up", func() {
+			records, total := newRecords("enable-3D", results, false)
+			So(records, ShouldHaveLength, 3)
+			So(records[0].Type, ShouldEqual, "result")
+			So(records[0].Name, ShouldEqual, "TV-1")
+			So(records[0].IP, ShouldEqual, "192.168.1.100")
+			So(records[0].Action, ShouldEqual, "enable-3D")
+			So(records[0].Success, ShouldBeTrue)
+			So(records[0].Outcome, ShouldEqual, "3D on")
+			So(records[0].LatencyMS, ShouldEqual, 1.5)
+			So(records[0].Error, ShouldBeNil)
+
+			So(total.Type, ShouldEqual, "summary")
+			So(total.Total, ShouldEqual, 3)
+			So(total.Succeeded, ShouldEqual, 2)
+			So(total.Failed, ShouldEqual, 1)
+			So(total.RolledBack, ShouldEqual, 1)
+			So(total.SkewMS, ShouldBeNil)
+		})
+
+		Convey("It should describe ROAP errors with their code", func() {
+			records, _ := newRecords("enable-3D", results, false)
+			So(records[1].Success, ShouldBeFalse)
+			So(*records[1].Error, ShouldResemble, errorRecord{Code: "unauthorized", ROAPError: 401, Detail: "Unauthorized"})
+
+			record := newErrorRecord(errors.New("no route to host"))
+			So(*record, ShouldResemble, errorRecord{Code: "error", Detail: "no route to host"})
+		})
+
+		Convey("It should only give the 3D state for 3D actions", func() {
+			records, _ := newRecords("query-3D-state", results, false)
+			So(records[0].State, ShouldEqual, "on")
+			So(records[1].State, ShouldEqual, "off")
+
+			records, _ = newRecords("volume set", results, false)
+			for _, record := range records {
+				So(record.State, ShouldEqual, "")
+			}
+		})
+
+		Convey("It should give the send skew of synchronized runs", func() {
+			records, total := newRecords("enable-3D", results, true)
+			So(*records[0].SkewMS, ShouldEqual, 2)
+			So(records[1].SkewMS, ShouldBeNil)
+			So(*records[2].SkewMS, ShouldEqual, 5)
+			So(*total.SkewMS, ShouldEqual, 3)
+		})
+
+		Convey("It should write one JSON document", func() {
+			var buf bytes.Buffer
+			p := &printer{format: "json", out: &buf}
+			p.results("enable-3D", results, false)
+
+			var v struct {
+				Results []map[string]interface{} `json:"results"`
+				Summary map[string]interface{}   `json:"summary"`
+			}
+			So(json.Unmarshal(buf.Bytes(), &v), ShouldBeNil)
+			So(v.Results, ShouldHaveLength, 3)
+			So(v.Results[1]["error"], ShouldResemble, map[string]interface{}{"code": "unauthorized", "roap_error": 401.0, "detail": "Unauthorized"})
+			So(v.Summary["failed"], ShouldEqual, 1)
+		})
+
+		Convey("It should write ndjson records as they are streamed, then the summary", func() {
+			var buf bytes.Buffer
+			p := &printer{format: "ndjson", out: &buf}
+			stream := p.stream("enable-3D", false, nil)
+			stream(results[2])
+			stream(results[0])
+			stream(results[1])
+			p.results("enable-3D", results, false)
+
+			lines := decodeLines(buf.String())
+			So(lines, ShouldHaveLength, 4)
+			So(lines[0]["name"], ShouldEqual, "TV-3")
+			So(lines[1]["name"], ShouldEqual, "TV-1")
+			So(lines[3]["type"], ShouldEqual, "summary")
+			So(lines[3]["total"], ShouldEqual, 3)
+
+			buf.Reset()
+			p.results("enable-3D", results, false)
+			So(decodeLines(buf.String()), ShouldHaveLength, 4)
+		})
+
+		Convey("It should only stream ndjson", func() {
+			So((&printer{format: "json"}).stream("enable-3D", false, nil), ShouldBeNil)
+			So((&printer{format: "text"}).stream("enable-3D", false, nil), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/neshmi/lg_remote/roap"
+)
+
+// pairTV shows the pairing key on tv, asks for the key displayed on screen,
+// checks it by authorizing a session and saves it in the config file
+func pairTV(ctx context.Context, client *roap.Client, tv *roap.TV, configFile string, in *bufio.Reader) error {
+	if err := client.DisplayPairingKey(ctx, tv); err != nil {
+		return err
+	}
+
+	// on stderr, out of the way of --output json
+	fmt.Fprintf(os.Stderr, "Enter the pairing key shown on %s (blank to skip): ", tv.Name)
+	line, err := in.ReadString('\n')
+	key := strings.TrimSpace(line)
+	if key == "" {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("skipped, no key entered")
+	}
+
+	previousKey := tv.Key
+	tv.Key = key
+	tv.Session = ""
+	if err := client.GetTVSession(ctx, tv); err != nil {
+		tv.Key = previousKey
+		return err
+	}
+
+	return roap.SetPairingKey(configFile, tv.Name, key)
+}
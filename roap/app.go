@@ -0,0 +1,172 @@
+package roap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// UnknownAppError is returned when no installed app matches a name or AUID
+type UnknownAppError struct {
+	Name string
+}
+
+func (e *UnknownAppError) Error() string {
+	return fmt.Sprintf("roap: no app matches %q, run `apps list` to see them", e.Name)
+}
+
+// AmbiguousAppError is returned when a name matches several apps equally well
+type AmbiguousAppError struct {
+	Name    string
+	Matches []string
+}
+
+func (e *AmbiguousAppError) Error() string {
+	return fmt.Sprintf("roap: %q matches %s, be more specific", e.Name, strings.Join(e.Matches, ", "))
+}
+
+// FindApp returns the app in apps with the AUID or name query. Names are
+// matched loosely, trying in turn the exact name, a prefix, a substring and
+// finally the letters of query in order, all ignoring case, spaces and
+// punctuation, so "youtube", "you" and "ytb" all find YouTube. A query that
+// matches several apps at the first step that matches any is an error.
+func FindApp(apps []App, query string) (*App, error) {
+	for i := range apps {
+		if apps[i].AUID == query {
+			return &apps[i], nil
+		}
+	}
+
+	want := normalizeAppName(query)
+	if want == "" {
+		return nil, &UnknownAppError{Name: query}
+	}
+	matchers := []func(name string) bool{
+		func(name string) bool { return name == want },
+		func(name string) bool { return strings.HasPrefix(name, want) },
+		func(name string) bool { return strings.Contains(name, want) },
+		func(name string) bool { return isSubsequence(want, name) },
+	}
+	for _, matches := range matchers {
+		var found []*App
+		for i := range apps {
+			if matches(normalizeAppName(apps[i].Name)) {
+				found = append(found, &apps[i])
+			}
+		}
+		switch len(found) {
+		case 0:
+			continue
+		case 1:
+			return found[0], nil
+		}
+
+		names := make([]string, len(found))
+		for i, app := range found {
+			names[i] = app.Name
+		}
+		return nil, &AmbiguousAppError{Name: query, Matches: names}
+	}
+	return nil, &UnknownAppError{Name: query}
+}
+
+// normalizeAppName lowercases name and drops everything but letters and digits
+func normalizeAppName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// isSubsequence reports whether the letters of short appear in long in order
+func isSubsequence(short string, long string) bool {
+	rest := long
+	for _, r := range short {
+		i := strings.IndexRune(rest, r)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(string(r)):]
+	}
+	return true
+}
+
+// xmlEscape escapes text for use inside an XML element
+func xmlEscape(text string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+// Apps returns the apps installed on the TV, from the cache unless it is
+// stale or refresh is set
+func (c *Client) Apps(ctx context.Context, tv *TV, refresh bool) ([]App, error) {
+	if c.Cache != nil && !refresh {
+		if apps, ok := c.Cache.Apps(tv); ok {
+			return apps, nil
+		}
+	}
+
+	apps, err := c.AppList(ctx, tv)
+	if err != nil {
+		return nil, err
+	}
+	if c.Cache != nil {
+		c.Cache.StoreApps(tv, apps)
+	}
+	return apps, nil
+}
+
+// findApp looks name up in the cached app list of the TV, fetching the list
+// again once if the app isn't in it, e.g. because it was just installed
+func (c *Client) findApp(ctx context.Context, tv *TV, name string) (*App, error) {
+	apps, err := c.Apps(ctx, tv, false)
+	if err != nil {
+		return nil, err
+	}
+	app, err := FindApp(apps, name)
+	var unknown *UnknownAppError
+	if errors.As(err, &unknown) && c.Cache != nil {
+		if apps, err = c.Apps(ctx, tv, true); err != nil {
+			return nil, err
+		}
+		app, err = FindApp(apps, name)
+	}
+	return app, err
+}
+
+// LaunchApp starts the app with the AUID or name on the TV, see FindApp
+func (c *Client) LaunchApp(ctx context.Context, tv *TV, name string) (*App, error) {
+	app, err := c.findApp(ctx, tv, name)
+	if err != nil {
+		return nil, err
+	}
+
+	commandBody := fmt.Sprintf(`<!--?xml version="1.0" encoding="utf-8"?--><command><name>AppExecute</name><auid>%s</auid><appname>%s</appname><contentId></contentId></command>`,
+		xmlEscape(app.AUID), xmlEscape(app.Name))
+	err = c.withSession(ctx, tv, func() error {
+		return c.postCommand(ctx, tv, commandBody, false)
+	})
+	return app, err
+}
+
+// TerminateApp closes the app with the AUID or name on the TV, see FindApp
+func (c *Client) TerminateApp(ctx context.Context, tv *TV, name string) (*App, error) {
+	app, err := c.findApp(ctx, tv, name)
+	if err != nil {
+		return nil, err
+	}
+
+	commandBody := fmt.Sprintf(`<!--?xml version="1.0" encoding="utf-8"?--><command><name>AppTerminate</name><auid>%s</auid><appname>%s</appname></command>`,
+		xmlEscape(app.AUID), xmlEscape(app.Name))
+	err = c.withSession(ctx, tv, func() error {
+		return c.postCommand(ctx, tv, commandBody, false)
+	})
+	return app, err
+}
@@ -0,0 +1,94 @@
+package roap
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestApps(t *testing.T) {
+	Convey("Given a TV with apps installed", t, func() {
+		client := NewClient()
+		tv := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123", Session: "1051689385"}
+		ctx := context.Background()
+
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=applist_get&type=1&index=0&number=0", httpmock.NewStringResponder(200, fixture("applist.xml")))
+
+		var commands []string
+		httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", func(req *http.Request) (*http.Response, error) {
+			body, _ := ioutil.ReadAll(req.Body)
+			commands = append(commands, string(body))
+			return httpmock.NewStringResponse(200, retrySuccess), nil
+		})
+
+		apps, err := client.AppList(ctx, tv)
+
+		Convey("It should parse the app list", func() {
+			So(err, ShouldBeNil)
+			So(apps, ShouldHaveLength, 4)
+			So(apps[3].Name, ShouldEqual, "CAVE Viewer & Stereo Test")
+			So(apps[3].AUID, ShouldEqual, "0000000000012a42")
+		})
+
+		Convey("It should find apps by AUID or loosely by name", func() {
+			for query, name := range map[string]string{
+				"0000000000011c65": "YouTube",
+				"youtube":          "YouTube",
+				"You":              "YouTube",
+				"ytb":              "YouTube",
+				"flix":             "Netflix",
+				"cave viewer":      "CAVE Viewer",
+				"stereo":           "CAVE Viewer & Stereo Test",
+			} {
+				app, err := FindApp(apps, query)
+				So(err, ShouldBeNil)
+				So(app.Name, ShouldEqual, name)
+			}
+		})
+
+		Convey("It should refuse names matching several apps or none", func() {
+			_, err := FindApp(apps, "cave")
+			So(err, ShouldHaveSameTypeAs, &AmbiguousAppError{})
+			So(err.(*AmbiguousAppError).Matches, ShouldHaveLength, 2)
+
+			_, err = FindApp(apps, "hulu")
+			So(err, ShouldHaveSameTypeAs, &UnknownAppError{})
+		})
+
+		Convey("It should launch with AppExecute and escape the name", func() {
+			app, err := client.LaunchApp(ctx, tv, "stereo")
+			So(err, ShouldBeNil)
+			So(app.AUID, ShouldEqual, "0000000000012a42")
+			So(commands, ShouldHaveLength, 1)
+			So(commands[0], ShouldContainSubstring, "<name>AppExecute</name><auid>0000000000012a42</auid><appname>CAVE Viewer &amp; Stereo Test</appname>")
+		})
+
+		Convey("It should terminate with AppTerminate", func() {
+			_, err := client.TerminateApp(ctx, tv, "netflix")
+			So(err, ShouldBeNil)
+			So(commands[0], ShouldContainSubstring, "<name>AppTerminate</name><auid>00000000000112ae</auid>")
+		})
+
+		Convey("It should use the cached app list", func() {
+			dir, err := ioutil.TempDir("", "lg_remote")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			client.Cache = OpenStateCache(filepath.Join(dir, "state.json"), time.Minute)
+
+			_, err = client.LaunchApp(ctx, tv, "youtube")
+			So(err, ShouldBeNil)
+			_, err = client.LaunchApp(ctx, tv, "netflix")
+			So(err, ShouldBeNil)
+			So(httpmock.GetCallCountInfo()["GET http://192.168.1.100:8080/roap/api/data?target=applist_get&type=1&index=0&number=0"], ShouldEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,168 @@
+package roap
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultStateCacheTTL is how long a cached session and 3D state are trusted
+const DefaultStateCacheTTL = 10 * time.Minute
+
+// DefaultChannelCacheTTL is how long a cached channel list is trusted, they
+// only change when the TV is tuned again
+const DefaultChannelCacheTTL = 24 * time.Hour
+
+// DefaultAppCacheTTL is how long a cached app list is trusted
+const DefaultAppCacheTTL = time.Hour
+
+// StateCache persists TV sessions and 3D state between runs, so every
+// invocation doesn't have to authorize every TV again
+type StateCache struct {
+	Path       string
+	TTL        time.Duration
+	ChannelTTL time.Duration
+	AppTTL     time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is the state remembered for a single TV
+type cacheEntry struct {
+	Session        string    `json:"session,omitempty"`
+	Current3DState string    `json:"current_3d_state,omitempty"`
+	Updated        time.Time `json:"updated"`
+	// Channels is the channel list, kept apart from the rest as it lives longer
+	Channels        []Channel `json:"channels,omitempty"`
+	ChannelsUpdated time.Time `json:"channels_updated,omitempty"`
+	Apps            []App     `json:"apps,omitempty"`
+	AppsUpdated     time.Time `json:"apps_updated,omitempty"`
+}
+
+// DefaultStateCachePath returns the cache file in the user's cache directory
+func DefaultStateCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "lg_remote", "state.json")
+}
+
+// OpenStateCache loads the cache file at path. A missing or unreadable cache
+// is not an error, it simply starts out empty.
+func OpenStateCache(path string, ttl time.Duration) *StateCache {
+	cache := &StateCache{Path: path, TTL: ttl, ChannelTTL: DefaultChannelCacheTTL, AppTTL: DefaultAppCacheTTL, entries: map[string]cacheEntry{}}
+
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		json.Unmarshal(data, &cache.entries)
+	}
+	if cache.entries == nil {
+		cache.entries = map[string]cacheEntry{}
+	}
+	return cache
+}
+
+// cacheKey identifies a TV by name and IP, so a TV moved to a new address
+// doesn't pick up another TV's session
+func cacheKey(tv *TV) string {
+	return tv.Name + "@" + tv.IP
+}
+
+// Restore fills in the session and 3D state of tv from the cache, if they
+// are still fresh. It reports whether a session was restored.
+func (sc *StateCache) Restore(tv *TV) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	entry, ok := sc.entries[cacheKey(tv)]
+	if !ok || time.Since(entry.Updated) > sc.TTL {
+		return false
+	}
+	if tv.Current3DState == "" {
+		tv.Current3DState = entry.Current3DState
+	}
+	if tv.Session == "" && entry.Session != "" {
+		tv.Session = entry.Session
+		return true
+	}
+	return false
+}
+
+// Store records the session and 3D state of tv and writes the cache to disk
+func (sc *StateCache) Store(tv *TV) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	entry := sc.entries[cacheKey(tv)]
+	entry.Session = tv.Session
+	entry.Current3DState = tv.Current3DState
+	entry.Updated = time.Now()
+	sc.entries[cacheKey(tv)] = entry
+	return sc.save()
+}
+
+// Channels returns the cached channel list of tv, if it is still fresh
+func (sc *StateCache) Channels(tv *TV) ([]Channel, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	entry, ok := sc.entries[cacheKey(tv)]
+	if !ok || entry.Channels == nil || time.Since(entry.ChannelsUpdated) > sc.ChannelTTL {
+		return nil, false
+	}
+	return entry.Channels, true
+}
+
+// StoreChannels records the channel list of tv and writes the cache to disk
+func (sc *StateCache) StoreChannels(tv *TV, channels []Channel) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	entry := sc.entries[cacheKey(tv)]
+	entry.Channels = channels
+	entry.ChannelsUpdated = time.Now()
+	sc.entries[cacheKey(tv)] = entry
+	return sc.save()
+}
+
+// Apps returns the cached app list of tv, if it is still fresh
+func (sc *StateCache) Apps(tv *TV) ([]App, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	entry, ok := sc.entries[cacheKey(tv)]
+	if !ok || entry.Apps == nil || time.Since(entry.AppsUpdated) > sc.AppTTL {
+		return nil, false
+	}
+	return entry.Apps, true
+}
+
+// StoreApps records the app list of tv and writes the cache to disk
+func (sc *StateCache) StoreApps(tv *TV, apps []App) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	entry := sc.entries[cacheKey(tv)]
+	entry.Apps = apps
+	entry.AppsUpdated = time.Now()
+	sc.entries[cacheKey(tv)] = entry
+	return sc.save()
+}
+
+// save writes the cache atomically, so concurrent runs never see a partial file
+func (sc *StateCache) save() error {
+	data, err := json.MarshalIndent(sc.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sc.Path), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(sc.Path, data, 0600)
+}
@@ -0,0 +1,88 @@
+package roap
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStateCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lg_remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	Convey("Given a state cache file", t, func() {
+		path := filepath.Join(dir, "state.json")
+		os.Remove(path)
+
+		Convey("It should persist sessions and 3D state between runs", func() {
+			cache := OpenStateCache(path, time.Minute)
+			So(cache.Store(&TV{Name: "TV-1", IP: "192.168.1.100", Session: "1051689385", Current3DState: "on"}), ShouldBeNil)
+
+			tv := &TV{Name: "TV-1", IP: "192.168.1.100"}
+			So(OpenStateCache(path, time.Minute).Restore(tv), ShouldBeTrue)
+			So(tv.Session, ShouldEqual, "1051689385")
+			So(tv.Current3DState, ShouldEqual, "on")
+		})
+
+		Convey("It should key entries by name and IP", func() {
+			cache := OpenStateCache(path, time.Minute)
+			cache.Store(&TV{Name: "TV-1", IP: "192.168.1.100", Session: "1051689385"})
+
+			moved := &TV{Name: "TV-1", IP: "192.168.1.200"}
+			So(cache.Restore(moved), ShouldBeFalse)
+			So(moved.Session, ShouldEqual, "")
+		})
+
+		Convey("It should ignore stale entries", func() {
+			cache := OpenStateCache(path, time.Nanosecond)
+			cache.Store(&TV{Name: "TV-1", IP: "192.168.1.100", Session: "1051689385", Current3DState: "on"})
+			time.Sleep(time.Millisecond)
+
+			tv := &TV{Name: "TV-1", IP: "192.168.1.100"}
+			So(cache.Restore(tv), ShouldBeFalse)
+			So(tv.Session, ShouldEqual, "")
+			So(tv.Current3DState, ShouldEqual, "")
+		})
+
+		Convey("It should start empty when the file is corrupt", func() {
+			ioutil.WriteFile(path, []byte("{not json"), 0600)
+			tv := &TV{Name: "TV-1", IP: "192.168.1.100"}
+			So(OpenStateCache(path, time.Minute).Restore(tv), ShouldBeFalse)
+		})
+
+		Convey("It should let a client skip authorization with a cached session", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			success := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail></envelope>
+			`
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", httpmock.NewStringResponder(200, success))
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", replies(is3DOff, is3DOn))
+
+			OpenStateCache(path, time.Minute).Store(&TV{Name: "TV-1", IP: "192.168.1.100", Session: "1051689385", Current3DState: "off"})
+
+			client := NewClient()
+			client.Enable3DDelay = time.Millisecond
+			client.Cache = OpenStateCache(path, time.Minute)
+			tv := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123"}
+
+			So(client.Enable3D(context.Background(), tv), ShouldBeNil)
+			So(tv.Session, ShouldEqual, "1051689385")
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.100:8080/roap/api/command"], ShouldEqual, 2)
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.100:8080/roap/api/auth"], ShouldEqual, 0)
+
+			restored := &TV{Name: "TV-1", IP: "192.168.1.100"}
+			OpenStateCache(path, time.Minute).Restore(restored)
+			So(restored.Current3DState, ShouldEqual, "on")
+		})
+	})
+}
@@ -0,0 +1,109 @@
+package roap
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UnknownChannelError is returned for a channel number the TV hasn't tuned
+type UnknownChannelError struct {
+	Number string
+}
+
+func (e *UnknownChannelError) Error() string {
+	return fmt.Sprintf("roap: no channel %s in the channel list, run `channels` to see them", e.Number)
+}
+
+// ParseChannelNumber splits a channel number such as "11" or "11-1" into its
+// major and minor parts, minor being zero when there is none
+func ParseChannelNumber(number string) (major int, minor int, err error) {
+	majorText, minorText, hasMinor := number, "", false
+	if i := strings.IndexAny(number, "-."); i >= 0 {
+		majorText, minorText, hasMinor = number[:i], number[i+1:], true
+	}
+
+	major, err = strconv.Atoi(majorText)
+	if err == nil && hasMinor {
+		minor, err = strconv.Atoi(minorText)
+	}
+	if err != nil || major < 0 || minor < 0 {
+		return 0, 0, fmt.Errorf("roap: bad channel number %q, expected major or major-minor, e.g. 11-1", number)
+	}
+	return major, minor, nil
+}
+
+// FindChannel returns the channel numbered major-minor in channels. Without a
+// minor number, the major channel itself is preferred and otherwise its
+// first subchannel is used.
+func FindChannel(channels []Channel, major int, minor int) (*Channel, bool) {
+	var found *Channel
+	for i := range channels {
+		ch := &channels[i]
+		if ch.Major != major {
+			continue
+		}
+		if ch.Minor == minor {
+			return ch, true
+		}
+		if minor == 0 && found == nil {
+			found = ch
+		}
+	}
+	return found, found != nil
+}
+
+// Channels returns the channel list of the TV, from the cache unless it is
+// stale or refresh is set
+func (c *Client) Channels(ctx context.Context, tv *TV, refresh bool) ([]Channel, error) {
+	if c.Cache != nil && !refresh {
+		if channels, ok := c.Cache.Channels(tv); ok {
+			return channels, nil
+		}
+	}
+
+	channels, err := c.ChannelList(ctx, tv)
+	if err != nil {
+		return nil, err
+	}
+	if c.Cache != nil {
+		c.Cache.StoreChannels(tv, channels)
+	}
+	return channels, nil
+}
+
+// ChangeChannel tunes the TV to the channel numbered major-minor with
+// HandleChannelChange. The channel is looked up in the cached channel list,
+// which is fetched again once if the channel isn't in it.
+func (c *Client) ChangeChannel(ctx context.Context, tv *TV, major int, minor int) (*Channel, error) {
+	channels, err := c.Channels(ctx, tv, false)
+	if err != nil {
+		return nil, err
+	}
+	ch, ok := FindChannel(channels, major, minor)
+	if !ok && c.Cache != nil {
+		channels, err = c.Channels(ctx, tv, true)
+		if err != nil {
+			return nil, err
+		}
+		ch, ok = FindChannel(channels, major, minor)
+	}
+	if !ok {
+		number := strconv.Itoa(major)
+		if minor > 0 {
+			number += "-" + strconv.Itoa(minor)
+		}
+		return nil, &UnknownChannelError{Number: number}
+	}
+
+	commandBody := fmt.Sprintf(`<!--?xml version="1.0" encoding="utf-8"?--><command><name>HandleChannelChange</name><major>%d</major><minor>%d</minor><sourceIndex>%d</sourceIndex><physicalNum>%d</physicalNum></command>`,
+		ch.Major, ch.Minor, ch.SourceIndex, ch.PhysicalNum)
+	err = c.withSession(ctx, tv, func() error {
+		return c.postCommand(ctx, tv, commandBody, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
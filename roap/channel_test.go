@@ -0,0 +1,124 @@
+package roap
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fixture returns the contents of a captured response in testdata
+func fixture(name string) string {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+func TestChannels(t *testing.T) {
+	Convey("Given channel numbers", t, func() {
+		Convey("It should parse major and minor parts", func() {
+			major, minor, err := ParseChannelNumber("11-1")
+			So(err, ShouldBeNil)
+			So([]int{major, minor}, ShouldResemble, []int{11, 1})
+
+			major, minor, err = ParseChannelNumber("74")
+			So(err, ShouldBeNil)
+			So([]int{major, minor}, ShouldResemble, []int{74, 0})
+
+			for _, bad := range []string{"", "abc", "11-", "-1", "11-x"} {
+				_, _, err = ParseChannelNumber(bad)
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+
+	Convey("Given captured channel responses", t, func() {
+		client := NewClient()
+		tv := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123", Session: "1051689385"}
+		ctx := context.Background()
+
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=channel_list", httpmock.NewStringResponder(200, fixture("channel_list.xml")))
+		httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=cur_channel", httpmock.NewStringResponder(200, fixture("cur_channel.xml")))
+
+		Convey("It should parse the channel list", func() {
+			channels, err := client.ChannelList(ctx, tv)
+			So(err, ShouldBeNil)
+			So(channels, ShouldHaveLength, 3)
+			So(channels[1], ShouldResemble, Channel{Type: "terrestrial", SourceIndex: 1, PhysicalNum: 22, Major: 9, Minor: 2, Name: "KQED-PL"})
+			So(channels[2].Number(), ShouldEqual, "74")
+		})
+
+		Convey("It should parse the current channel", func() {
+			ch, err := client.CurrentChannel(ctx, tv)
+			So(err, ShouldBeNil)
+			So(ch.Number(), ShouldEqual, "9-2")
+			So(ch.Program, ShouldEqual, "Evening News")
+			So(ch.String(), ShouldEqual, "9-2 KQED-PL (TV)")
+		})
+
+		Convey("It should find channels with and without a minor number", func() {
+			channels, _ := client.ChannelList(ctx, tv)
+
+			ch, ok := FindChannel(channels, 9, 2)
+			So(ok, ShouldBeTrue)
+			So(ch.Name, ShouldEqual, "KQED-PL")
+
+			ch, ok = FindChannel(channels, 9, 0)
+			So(ok, ShouldBeTrue)
+			So(ch.Name, ShouldEqual, "KQED-HD")
+
+			_, ok = FindChannel(channels, 9, 7)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("It should tune with HandleChannelChange", func() {
+			var body string
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", func(req *http.Request) (*http.Response, error) {
+				data, _ := ioutil.ReadAll(req.Body)
+				body = string(data)
+				return httpmock.NewStringResponse(200, retrySuccess), nil
+			})
+
+			ch, err := client.ChangeChannel(ctx, tv, 74, 0)
+			So(err, ShouldBeNil)
+			So(ch.Name, ShouldEqual, "CNN")
+			So(body, ShouldContainSubstring, "<name>HandleChannelChange</name>")
+			So(body, ShouldContainSubstring, "<major>74</major><minor>0</minor><sourceIndex>2</sourceIndex><physicalNum>74</physicalNum>")
+
+			_, err = client.ChangeChannel(ctx, tv, 5, 1)
+			So(err, ShouldHaveSameTypeAs, &UnknownChannelError{})
+		})
+
+		Convey("It should cache the channel list", func() {
+			dir, err := ioutil.TempDir("", "lg_remote")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			client.Cache = OpenStateCache(filepath.Join(dir, "state.json"), time.Minute)
+
+			channels, err := client.Channels(ctx, tv, false)
+			So(err, ShouldBeNil)
+			So(channels, ShouldHaveLength, 3)
+
+			cached := NewClient()
+			cached.Cache = OpenStateCache(filepath.Join(dir, "state.json"), time.Minute)
+			channels, err = cached.Channels(ctx, tv, false)
+			So(err, ShouldBeNil)
+			So(channels, ShouldHaveLength, 3)
+			So(httpmock.GetCallCountInfo()["GET http://192.168.1.100:8080/roap/api/data?target=channel_list"], ShouldEqual, 1)
+
+			_, err = cached.Channels(ctx, tv, true)
+			So(err, ShouldBeNil)
+			So(httpmock.GetCallCountInfo()["GET http://192.168.1.100:8080/roap/api/data?target=channel_list"], ShouldEqual, 2)
+		})
+	})
+}
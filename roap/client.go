@@ -0,0 +1,274 @@
+package roap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultConnectTimeout bounds how long a connection to a TV may take
+const DefaultConnectTimeout = 3 * time.Second
+
+// DefaultReadTimeout bounds how long a TV may take to answer a request
+const DefaultReadTimeout = 5 * time.Second
+
+// DefaultEnable3DDelay is how long the TV gets to open its 3D menu before
+// the selection is confirmed
+const DefaultEnable3DDelay = time.Second
+
+// DefaultEnable3DRetries is how many times the 3D confirmation is sent again
+// when the TV has not switched to 3D
+const DefaultEnable3DRetries = 2
+
+// DefaultStateTimeout bounds how long a TV may take to confirm a 3D change
+const DefaultStateTimeout = 10 * time.Second
+
+// Client talks to LG TVs over the ROAP API
+type Client struct {
+	HTTPClient *http.Client
+	// Cache, when set, keeps sessions and 3D state across runs
+	Cache *StateCache
+	// Enable3DDelay is the pause between the 3D key and its confirmation,
+	// unless the TV sets its own
+	Enable3DDelay   time.Duration
+	Enable3DRetries int
+	// StateTimeout bounds how long Set3D polls for the TV to confirm a change
+	StateTimeout time.Duration
+	// Retry is the retry policy for TVs that don't set their own
+	Retry RetryPolicy
+	// VolumeKeyDelay is the pause between volume key presses
+	VolumeKeyDelay time.Duration
+	// ConnectTimeout bounds the connection Status checks reachability with
+	ConnectTimeout time.Duration
+	// Macros are the configured macros, which replace BuiltinMacros of the
+	// same name, e.g. the keys Enable3D presses
+	Macros map[string][]string
+
+	// dial opens the connection Status checks reachability with, a plain
+	// net.Dialer when nil
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// NewClient returns a Client using the default HTTP client
+func NewClient() *Client {
+	return &Client{
+		HTTPClient:      http.DefaultClient,
+		Enable3DDelay:   DefaultEnable3DDelay,
+		Enable3DRetries: DefaultEnable3DRetries,
+		StateTimeout:    DefaultStateTimeout,
+		Retry:           DefaultRetryPolicy,
+		VolumeKeyDelay:  DefaultVolumeKeyDelay,
+		ConnectTimeout:  DefaultConnectTimeout,
+	}
+}
+
+// NewClientWithTimeouts returns a Client whose connections give up after
+// connectTimeout and whose requests give up waiting for an answer after
+// readTimeout. The read timeout covers the whole answer, so a TV that sends
+// headers and then stalls can't hang a request either. A zero timeout means
+// no timeout.
+func NewClientWithTimeouts(connectTimeout, readTimeout time.Duration) *Client {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: connectTimeout}).DialContext,
+		ResponseHeaderTimeout: readTimeout,
+	}
+	var timeout time.Duration
+	if readTimeout > 0 {
+		timeout = connectTimeout + readTimeout
+	}
+	client := NewClient()
+	client.HTTPClient = &http.Client{Transport: transport, Timeout: timeout}
+	client.ConnectTimeout = connectTimeout
+	return client
+}
+
+// envelope is the wrapper around every ROAP response
+type envelope struct {
+	XMLName xml.Name `xml:"envelope"`
+	Code    int      `xml:"ROAPError"`
+	Detail  string   `xml:"ROAPErrorDetail"`
+}
+
+// decodeResponse reads a ROAP response into v and returns the error reported
+// in its envelope, if any
+func decodeResponse(path string, resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+
+	body, readerr := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		// TVs reject a stale session with HTTP 401 and a ROAP 401 envelope,
+		// report the envelope's error so it can be told apart
+		env := envelope{}
+		if readerr == nil && xml.Unmarshal(body, &env) == nil && env.Code != 0 && env.Code != 200 {
+			return &ROAPError{Code: env.Code, Detail: env.Detail}
+		}
+		return &TransportError{Path: path, StatusCode: resp.StatusCode}
+	}
+	if readerr != nil {
+		return &TransportError{Path: path, Err: readerr}
+	}
+
+	env := envelope{}
+	if err := xml.Unmarshal(body, &env); err != nil {
+		return &ParseError{Path: path, Err: err}
+	}
+	if v != nil {
+		if err := xml.Unmarshal(body, v); err != nil {
+			return &ParseError{Path: path, Err: err}
+		}
+	}
+
+	if env.Code != 200 || env.Detail != "OK" {
+		return &ROAPError{Code: env.Code, Detail: env.Detail}
+	}
+	return nil
+}
+
+// Check3D will check to see if a TV is currently in 3D mode and record it in
+// Current3DState, see Get3DState
+func (c *Client) Check3D(ctx context.Context, tv *TV) error {
+	state, err := c.Get3DState(ctx, tv)
+	tv.Current3DState = state
+	if err != nil {
+		return err
+	}
+	c.remember(tv)
+	return nil
+}
+
+// restore loads the cached session and 3D state of tv, reporting whether a
+// session was found
+func (c *Client) restore(tv *TV) bool {
+	if c.Cache == nil {
+		return false
+	}
+	return c.Cache.Restore(tv)
+}
+
+// remember stores the session and 3D state of tv in the cache. A cache that
+// can't be written only costs a new session next time, so errors are dropped.
+func (c *Client) remember(tv *TV) {
+	if c.Cache != nil {
+		c.Cache.Store(tv)
+	}
+}
+
+// SendXML will post XML to the TV and return the response, retrying
+// transient failures as the retry policy allows
+func (c *Client) SendXML(ctx context.Context, tv *TV, data string, path string) (response *http.Response, err error) {
+	return c.sendXML(ctx, tv, data, path, false)
+}
+
+// sendXML is SendXML for requests that may be unsafe to repeat, see RetryPolicy
+func (c *Client) sendXML(ctx context.Context, tv *TV, data string, path string, unsafe bool) (*http.Response, error) {
+	url := BuildURI(tv, path)
+	if path == "/command" {
+		markSent(ctx)
+	}
+	return c.do(ctx, tv, path, unsafe, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", url, strings.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "atom+xml")
+		return req, nil
+	})
+}
+
+// DisplayPairingKey causes the pairing key to be displayed on the passed TV object
+func (c *Client) DisplayPairingKey(ctx context.Context, tv *TV) error {
+	commandBody := `<!--?xml version=\"1.0\" encoding=\"utf-8\"?--><auth><type>AuthKeyReq</type></auth>`
+
+	// asking twice would show a new key while the first is being read
+	resp, err := c.sendXML(ctx, tv, commandBody, "/auth", true)
+	if err != nil {
+		return err
+	}
+
+	return decodeResponse("/auth", resp, nil)
+}
+
+// SendCommand to TV, 400 activates the 3D mode, 20 is the okay button.
+// If the TV has dropped the session, e.g. after a reboot or standby, the
+// session is authorized again with the stored key and the command retried once.
+func (c *Client) SendCommand(ctx context.Context, tv *TV, command string) error {
+	return c.withSession(ctx, tv, func() error {
+		return c.sendKey(ctx, tv, command)
+	})
+}
+
+// withSession calls send once tv has a session, authorizing one if needed.
+// If the TV rejects a session that wasn't just authorized, it is authorized
+// again and send called once more.
+func (c *Client) withSession(ctx context.Context, tv *TV, send func() error) error {
+	freshSession := false
+	if tv.Session == "" && !c.restore(tv) {
+		if err := c.GetTVSession(ctx, tv); err != nil {
+			return err
+		}
+		freshSession = true
+	}
+
+	err := send()
+	if IsUnauthorized(err) && !freshSession {
+		tv.Session = ""
+		if err := c.GetTVSession(ctx, tv); err != nil {
+			return err
+		}
+		err = send()
+	}
+	return err
+}
+
+// sendKey posts a single HandleKeyInput command using the current session
+func (c *Client) sendKey(ctx context.Context, tv *TV, command string) error {
+	commandBody := fmt.Sprintf(`<!--?xml version="1.0" encoding="utf-8"?--><command><name>HandleKeyInput</name><value>%s</value></command>`, command)
+
+	// a repeated toggle undoes itself, e.g. switches the TV straight back on
+	return c.postCommand(ctx, tv, commandBody, IsToggle(command))
+}
+
+// postCommand posts a command to the TV using the current session
+func (c *Client) postCommand(ctx context.Context, tv *TV, commandBody string, unsafe bool) error {
+	resp, err := c.sendXML(ctx, tv, commandBody, "/command", unsafe)
+	if err != nil {
+		return err
+	}
+
+	return decodeResponse("/command", resp, nil)
+}
+
+// GetTVSession authorizes a Session
+func (c *Client) GetTVSession(ctx context.Context, tv *TV) error {
+	// abort if pairing key isn't present
+	if tv.Key == "" {
+		return ErrNoPairingKey
+	}
+
+	commandBody := fmt.Sprintf(`<!--?xml version="1.0" encoding="utf-8"?--><auth><type>AuthReq</type><value>%s</value></auth>`, tv.Key)
+
+	type Result struct {
+		XMLName   xml.Name `xml:"envelope"`
+		SessionID string   `xml:"session"`
+	}
+
+	v := Result{}
+
+	resp, err := c.SendXML(ctx, tv, commandBody, "/auth")
+	if err != nil {
+		return err
+	}
+
+	if err := decodeResponse("/auth", resp, &v); err != nil {
+		return err
+	}
+	tv.Session = v.SessionID
+	c.remember(tv)
+	return nil
+}
@@ -0,0 +1,557 @@
+package roap
+
+//successful return of command
+// <?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail></envelope>
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const is3DOn = `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><data><is3D>true</is3D></data></envelope>`
+
+const is3DOff = `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><data><is3D>false</is3D></data></envelope>`
+
+// replies answers each request with the next body, repeating the last one
+func replies(bodies ...string) httpmock.Responder {
+	calls := 0
+	return func(req *http.Request) (*http.Response, error) {
+		body := bodies[calls]
+		if calls < len(bodies)-1 {
+			calls++
+		}
+		return httpmock.NewStringResponse(200, body), nil
+	}
+}
+
+func TestLgRemote(t *testing.T) {
+	tv1 := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123", Current3DState: "off"}
+	tv2 := &TV{Name: "TV-2", IP: "192.168.1.101", Key: "123xyz", Current3DState: "off"}
+	tv3 := &TV{Name: "TV-2", IP: "192.168.1.102", Key: "123xyz", Current3DState: "off"}
+	client := NewClient()
+	client.Enable3DDelay = time.Millisecond
+	client.StateTimeout = 50 * time.Millisecond
+	ctx := context.Background()
+
+	os.Setenv("LG_REMOTE_PATH", "testdata")
+	os.Setenv("LG_REMOTE_CONFIG_FILE", "tv_config.json")
+
+	Convey("Given a TV Configuration file", t, func() {
+		tvsFromJSON, err := GetAllTVs()
+		Convey("It should return an array of TVs and Codes", func() {
+			So(err, ShouldBeNil)
+			// Loading from testdata/tv_config.json, 2 TVs in the config file
+			So(tvsFromJSON, ShouldHaveLength, 2)
+			// Test first part of the slice
+			So(tvsFromJSON[0].Name, ShouldEqual, "TV-1")
+			So(tvsFromJSON[0].IP, ShouldEqual, "192.168.1.100")
+			So(tvsFromJSON[0].Key, ShouldEqual, "xyz123")
+			// Test second part of the slice
+			So(tvsFromJSON[1].Name, ShouldEqual, "TV-2")
+			So(tvsFromJSON[1].IP, ShouldEqual, "192.168.1.101")
+			So(tvsFromJSON[1].Key, ShouldEqual, "123xyz")
+		})
+	})
+
+	Convey("Given a TV and URI path", t, func() {
+		// tv1 := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123"}
+
+		Convey("It should return a complete URI path", func() {
+			So(BuildURI(tv1, "/auth"), ShouldEqual, "http://192.168.1.100:8080/roap/api/auth")
+		})
+	})
+
+	Convey("Given a TV without a pairing key", t, func() {
+		Convey("It should display pairing key on the TV", func() {
+			response := `
+			<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><session>1051689385</session></envelope>
+			`
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/auth", httpmock.NewStringResponder(200, response))
+
+			httpmock.RegisterResponder("POST", "http://192.168.1.101:8080/roap/api/auth", httpmock.NewStringResponder(500, response))
+
+			So(client.DisplayPairingKey(ctx, tv1), ShouldBeNil)
+			err := client.DisplayPairingKey(ctx, tv2)
+			So(err, ShouldHaveSameTypeAs, &TransportError{})
+			So(err.(*TransportError).StatusCode, ShouldEqual, 500)
+		})
+
+	})
+
+	Convey("Given a TV", t, func() {
+		Convey("It should query its 3D state", func() {
+			falseMode := `
+			<?xml version="1.0" encoding="utf-8"?>
+			<envelope>
+				<ROAPError>200</ROAPError>
+				<ROAPErrorDetail>OK</ROAPErrorDetail>
+				<data>
+					<is3D>false</is3D>
+				</data>
+			</envelope>
+			`
+
+			trueMode := `
+			<?xml version="1.0" encoding="utf-8"?>
+			<envelope>
+				<ROAPError>200</ROAPError>
+				<ROAPErrorDetail>OK</ROAPErrorDetail>
+				<data>
+					<is3D>true</is3D>
+				</data>
+			</envelope>
+			`
+
+			unAuthorized := `
+			<?xml version="1.0" encoding="utf-8"?>
+			<envelope>
+				<ROAPError>400</ROAPError>
+				<ROAPErrorDetail>unauthorzed</ROAPErrorDetail>
+				<data>
+					<is3D>unauthorzed</is3D>
+				</data>
+			</envelope>
+			`
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, falseMode))
+
+			httpmock.RegisterResponder("GET", "http://192.168.1.101:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, trueMode))
+
+			httpmock.RegisterResponder("GET", "http://192.168.1.102:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, unAuthorized))
+			// Set Mock Server to return false for TV1
+			// So(tv1.Is3D(), ShouldEqual, "false")
+			So(tv1.Current3DState, ShouldEqual, "off")
+			So(client.Check3D(ctx, tv1), ShouldBeNil)
+			So(tv1.Current3DState, ShouldEqual, "off")
+
+			// Set Mock Server to return true for TV2, should switch the state of the TV record
+			So(tv2.Current3DState, ShouldEqual, "off")
+			So(client.Check3D(ctx, tv2), ShouldBeNil)
+			So(tv2.Current3DState, ShouldEqual, "on")
+
+			// Set Mock Server to return an error for TV3, should switch to unknown and report the ROAPError
+			So(tv3.Current3DState, ShouldEqual, "off")
+			err := client.Check3D(ctx, tv3)
+			So(err, ShouldHaveSameTypeAs, &ROAPError{})
+			So(err.(*ROAPError).Code, ShouldEqual, 400)
+			So(err.(*ROAPError).Detail, ShouldEqual, "unauthorzed")
+			So(tv3.Current3DState, ShouldEqual, "unknown")
+		})
+
+		Convey("It should report an unreachable TV instead of panicking", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			refused := errors.New("dial tcp 192.168.1.100:8080: connect: connection refused")
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", httpmock.NewErrorResponder(refused))
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/auth", httpmock.NewErrorResponder(refused))
+
+			tv1.Session = ""
+
+			err := client.Check3D(ctx, tv1)
+			So(err, ShouldHaveSameTypeAs, &TransportError{})
+			So(err.Error(), ShouldContainSubstring, "connection refused")
+			So(tv1.Current3DState, ShouldEqual, "no-response")
+
+			So(client.GetTVSession(ctx, tv1), ShouldHaveSameTypeAs, &TransportError{})
+			So(client.SendCommand(ctx, tv1, "1"), ShouldHaveSameTypeAs, &TransportError{})
+			So(client.DisplayPairingKey(ctx, tv1), ShouldHaveSameTypeAs, &TransportError{})
+		})
+
+		Convey("It should give up when the context deadline passes", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			hung := func(req *http.Request) (*http.Response, error) {
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			}
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", hung)
+
+			deadline, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+
+			err := client.Check3D(deadline, tv1)
+			So(err, ShouldHaveSameTypeAs, &TransportError{})
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+		})
+
+		Convey("It should report a malformed response", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, "<html>not roap"))
+
+			So(client.Check3D(ctx, tv1), ShouldHaveSameTypeAs, &ParseError{})
+		})
+
+		Convey("It should enable the 3D", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			authorizedSuccess := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail></envelope>
+			`
+			authorizedFail := `
+			<?xml version="1.0" encoding="utf-8"?> <envelope> <ROAPError>401</ROAPError> <ROAPErrorDetail>Unauthorized</ROAPErrorDetail> </envelope>
+			`
+			unauthorized := `
+			<?xml version="1.0" encoding="utf-8"?> <envelope> <ROAPError>401</ROAPError> <ROAPErrorDetail>Unauthorized</ROAPErrorDetail> </envelope>
+			`
+			sessionSuccess := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><session>1051689385</session></envelope>
+			`
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/auth", httpmock.NewStringResponder(200, sessionSuccess))
+
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", httpmock.NewStringResponder(200, authorizedSuccess))
+
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", replies(is3DOff, is3DOn))
+
+			httpmock.RegisterResponder("POST", "http://192.168.1.101:8080/roap/api/command", httpmock.NewStringResponder(200, authorizedFail))
+
+			httpmock.RegisterResponder("GET", "http://192.168.1.101:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, is3DOff))
+
+			httpmock.RegisterResponder("POST", "http://192.168.1.102:8080/roap/api/command", httpmock.NewStringResponder(200, unauthorized))
+
+			httpmock.RegisterResponder("GET", "http://192.168.1.102:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, is3DOff))
+
+			tv1.Session = ""
+			tv1.Current3DState = "off"
+			tv2.Current3DState = "off"
+			tv3.Current3DState = "off"
+
+			So(tv1.Session, ShouldEqual, "")
+			So(client.Enable3D(ctx, tv1), ShouldBeNil)
+			So(tv1.Current3DState, ShouldEqual, "on")
+			So(tv1.Session, ShouldEqual, "1051689385")
+			So(client.Enable3D(ctx, tv2), ShouldNotBeNil)
+			So(tv2.Current3DState, ShouldEqual, "off")
+			So(client.Enable3D(ctx, tv3), ShouldNotBeNil)
+			So(tv3.Current3DState, ShouldEqual, "off")
+		})
+
+		Convey("It should only send keys when the TV is not already in the wanted state", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, is3DOff))
+			httpmock.RegisterResponder("GET", "http://192.168.1.101:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, is3DOn))
+
+			// The cache believes TV-1 is in 3D, but the TV says otherwise, so
+			// toggling blindly would switch it on
+			tv1.Session = "1051689385"
+			tv1.Current3DState = "on"
+			transition, err := client.Set3D(ctx, tv1, "off")
+			So(err, ShouldBeNil)
+			So(transition.Changed, ShouldBeFalse)
+			So(transition.Drifted(), ShouldBeTrue)
+			So(transition.Cached, ShouldEqual, "on")
+			So(transition.Before, ShouldEqual, "off")
+			So(tv1.Current3DState, ShouldEqual, "off")
+
+			tv2.Session = "1051689385"
+			tv2.Current3DState = ""
+			transition, err = client.Set3D(ctx, tv2, "on")
+			So(err, ShouldBeNil)
+			So(transition.Changed, ShouldBeFalse)
+			So(transition.Drifted(), ShouldBeFalse)
+
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.100:8080/roap/api/command"], ShouldEqual, 0)
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.101:8080/roap/api/command"], ShouldEqual, 0)
+		})
+
+		Convey("It should not send keys when the TV doesn't report its 3D state", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			noState := `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail></envelope>`
+			unknownState := `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><data><is3D>maybe</is3D></data></envelope>`
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, noState))
+			httpmock.RegisterResponder("GET", "http://192.168.1.101:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, unknownState))
+
+			tv1.Session = "1051689385"
+			tv1.Current3DState = "on"
+			transition, err := client.Set3D(ctx, tv1, "off")
+			So(err, ShouldHaveSameTypeAs, &UnknownStateError{})
+			So(transition.Before, ShouldEqual, "no-response")
+			So(transition.Changed, ShouldBeFalse)
+
+			tv2.Session = "1051689385"
+			_, err = client.Set3D(ctx, tv2, "on")
+			So(err, ShouldHaveSameTypeAs, &UnknownStateError{})
+			So(ErrorCode(err), ShouldEqual, "unknown_state")
+
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.100:8080/roap/api/command"], ShouldEqual, 0)
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.101:8080/roap/api/command"], ShouldEqual, 0)
+		})
+
+		Convey("It should confirm 3D again until the TV reports it", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			success := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail></envelope>
+			`
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", httpmock.NewStringResponder(200, success))
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", replies(is3DOff, is3DOff, is3DOn))
+			httpmock.RegisterResponder("POST", "http://192.168.1.101:8080/roap/api/command", httpmock.NewStringResponder(200, success))
+			httpmock.RegisterResponder("GET", "http://192.168.1.101:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, is3DOff))
+
+			tv1.Session = "1051689385"
+			tv1.Current3DState = "off"
+			So(client.Enable3D(ctx, tv1), ShouldBeNil)
+			So(tv1.Current3DState, ShouldEqual, "on")
+			// 3D, confirm, then one more confirm after the first check
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.100:8080/roap/api/command"], ShouldEqual, 3)
+
+			// TV-2 never switches, so give up once the state timeout passes
+			tv2.Session = "1051689385"
+			tv2.Current3DState = "off"
+			err := client.Enable3D(ctx, tv2)
+			So(err, ShouldHaveSameTypeAs, &StateError{})
+			So(tv2.Current3DState, ShouldEqual, "off")
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.101:8080/roap/api/command"], ShouldEqual, 2+client.Enable3DRetries)
+		})
+
+		Convey("It should prefer the delay configured on the TV", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			success := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail></envelope>
+			`
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", httpmock.NewStringResponder(200, success))
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", replies(is3DOff, is3DOn))
+
+			tv := &TV{Name: "TV-1", IP: "192.168.1.100", Session: "1051689385", Current3DState: "off"}
+			tv.Enable3DDelay.Duration = 50 * time.Millisecond
+
+			start := time.Now()
+			So(client.Enable3D(ctx, tv), ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 100*time.Millisecond)
+		})
+
+		Convey("It should disable the 3D", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			authorizedSuccess := `
+			<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><session>1051689385</session></envelope>
+			`
+			authorizedFail := `
+			<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>FAIL</ROAPErrorDetail><session>1051689385</session></envelope>
+			`
+			unauthorized := `
+			<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>FAIL</ROAPErrorDetail><session>1051689385</session></envelope>
+			`
+
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", httpmock.NewStringResponder(200, authorizedSuccess))
+
+			httpmock.RegisterResponder("POST", "http://192.168.1.101:8080/roap/api/command", httpmock.NewStringResponder(200, authorizedFail))
+
+			httpmock.RegisterResponder("POST", "http://192.168.1.102:8080/roap/api/command", httpmock.NewStringResponder(200, unauthorized))
+
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", replies(is3DOn, is3DOff))
+
+			httpmock.RegisterResponder("GET", "http://192.168.1.101:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, is3DOn))
+
+			httpmock.RegisterResponder("GET", "http://192.168.1.102:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, is3DOn))
+
+			tv1.Current3DState = "on"
+			tv2.Current3DState = "on"
+			tv3.Current3DState = "on"
+
+			So(client.Disable3D(ctx, tv1), ShouldBeNil)
+			So(tv1.Current3DState, ShouldEqual, "off")
+			So(client.Disable3D(ctx, tv2), ShouldNotBeNil)
+			So(tv2.Current3DState, ShouldEqual, "on")
+			So(client.Disable3D(ctx, tv3), ShouldNotBeNil)
+			So(tv3.Current3DState, ShouldEqual, "on")
+		})
+
+		Convey("It should re-authorize and retry once when the TV drops the session", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			success := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail></envelope>
+			`
+			unauthorized := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>401</ROAPError><ROAPErrorDetail>Unauthorized</ROAPErrorDetail></envelope>
+			`
+			sessionSuccess := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><session>2051689385</session></envelope>
+			`
+
+			commands := 0
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", func(req *http.Request) (*http.Response, error) {
+				commands++
+				if commands == 1 {
+					return httpmock.NewStringResponse(200, unauthorized), nil
+				}
+				return httpmock.NewStringResponse(200, success), nil
+			})
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/auth", httpmock.NewStringResponder(200, sessionSuccess))
+			httpmock.RegisterResponder("POST", "http://192.168.1.101:8080/roap/api/command", httpmock.NewStringResponder(200, unauthorized))
+			httpmock.RegisterResponder("POST", "http://192.168.1.101:8080/roap/api/auth", httpmock.NewStringResponder(200, unauthorized))
+
+			tv1.Session = "1051689385"
+			So(client.SendCommand(ctx, tv1, "20"), ShouldBeNil)
+			So(tv1.Session, ShouldEqual, "2051689385")
+			So(commands, ShouldEqual, 2)
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.100:8080/roap/api/auth"], ShouldEqual, 1)
+
+			// Re-authorization is rejected too, so the key itself is bad
+			tv2.Session = "1051689385"
+			err := client.SendCommand(ctx, tv2, "20")
+			So(IsUnauthorized(err), ShouldBeTrue)
+			So(tv2.Session, ShouldEqual, "")
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.101:8080/roap/api/command"], ShouldEqual, 1)
+		})
+
+		Convey("It should re-authorize when the TV rejects the session with HTTP 401", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			success := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail></envelope>
+			`
+			unauthorized := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>401</ROAPError><ROAPErrorDetail>Unauthorized</ROAPErrorDetail></envelope>
+			`
+			sessionSuccess := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><session>2051689385</session></envelope>
+			`
+
+			commands := 0
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", func(req *http.Request) (*http.Response, error) {
+				commands++
+				if commands == 1 {
+					return httpmock.NewStringResponse(401, unauthorized), nil
+				}
+				return httpmock.NewStringResponse(200, success), nil
+			})
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/auth", httpmock.NewStringResponder(200, sessionSuccess))
+			httpmock.RegisterResponder("POST", "http://192.168.1.101:8080/roap/api/command", httpmock.NewStringResponder(401, unauthorized))
+			httpmock.RegisterResponder("POST", "http://192.168.1.101:8080/roap/api/auth", httpmock.NewStringResponder(401, unauthorized))
+			httpmock.RegisterResponder("POST", "http://192.168.1.102:8080/roap/api/command", httpmock.NewStringResponder(404, "Not Found"))
+
+			tv1.Session = "1051689385"
+			So(client.SendCommand(ctx, tv1, "20"), ShouldBeNil)
+			So(tv1.Session, ShouldEqual, "2051689385")
+			So(commands, ShouldEqual, 2)
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.100:8080/roap/api/auth"], ShouldEqual, 1)
+
+			tv2.Session = "1051689385"
+			err := client.SendCommand(ctx, tv2, "20")
+			So(IsUnauthorized(err), ShouldBeTrue)
+			So(ErrorCode(err), ShouldEqual, "unauthorized")
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.101:8080/roap/api/auth"], ShouldEqual, 1)
+
+			// a body that isn't ROAP is still reported as an HTTP error
+			tv3.Session = "1051689385"
+			err = client.SendCommand(ctx, tv3, "20")
+			So(ErrorCode(err), ShouldEqual, "http_status")
+		})
+
+		Convey("It should get the session if it has a pairing key", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			sessionSuccess := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><session>1051689385</session></envelope>
+			`
+
+			sessionFail := `
+				<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>401</ROAPError><ROAPErrorDetail>Unauthorized</ROAPErrorDetail></envelope>
+			`
+
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/auth", httpmock.NewStringResponder(200, sessionSuccess))
+
+			httpmock.RegisterResponder("POST", "http://192.168.1.101:8080/roap/api/auth", httpmock.NewStringResponder(200, sessionFail))
+
+			tv1.Session = ""
+			tv2.Session = ""
+
+			So(tv1.Session, ShouldEqual, "")
+			So(client.GetTVSession(ctx, tv1), ShouldBeNil)
+			So(tv1.Session, ShouldEqual, "1051689385")
+
+			So(tv2.Session, ShouldEqual, "")
+			err := client.GetTVSession(ctx, tv2)
+			So(err, ShouldNotBeNil)
+			So(IsUnauthorized(err), ShouldBeTrue)
+			So(tv2.Session, ShouldEqual, "")
+		})
+
+		Convey("It should refuse to get a session without a pairing key", func() {
+			tv := &TV{Name: "TV-4", IP: "192.168.1.103"}
+			So(client.GetTVSession(ctx, tv), ShouldEqual, ErrNoPairingKey)
+		})
+
+	})
+
+	Convey("Given a group of TVs", t, func() {
+		Convey("It should find a TV by name", func() {
+			tvs, _ := GetAllTVs()
+			tvTest1, err1 := FindTvByName("TV-1", tvs)
+			tvTest2, err2 := FindTvByName("TV-2", tvs)
+			So(err1, ShouldBeNil)
+			So(err2, ShouldBeNil)
+			So(tvTest1.Name, ShouldEqual, "TV-1")
+			So(tvTest2.Name, ShouldEqual, "TV-2")
+		})
+
+		Convey("It should keep changes to the TV it finds", func() {
+			tvs, _ := GetAllTVs()
+			tv, _ := FindTvByName("TV-2", tvs)
+			tv.Session = "1051689385"
+			So(tvs[1].Session, ShouldEqual, "1051689385")
+		})
+
+		Convey("It should report a TV it can't find", func() {
+			tvs, _ := GetAllTVs()
+			tv, err := FindTvByName("TV-9", tvs)
+			So(tv, ShouldBeNil)
+			So(err, ShouldHaveSameTypeAs, &UnknownTVError{})
+		})
+	})
+
+}
+
+// stallingTransport answers every request with headers and then a body that
+// never arrives, until the request is canceled
+type stallingTransport struct{}
+
+func (stallingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: 200, Body: stallingBody{req.Context()}, Request: req}, nil
+}
+
+type stallingBody struct {
+	ctx context.Context
+}
+
+func (b stallingBody) Read(p []byte) (int, error) {
+	<-b.ctx.Done()
+	return 0, b.ctx.Err()
+}
+
+func (b stallingBody) Close() error {
+	return nil
+}
+
+func TestTimeouts(t *testing.T) {
+	Convey("Given a TV that stalls after sending headers", t, func() {
+		client := NewClientWithTimeouts(10*time.Millisecond, 20*time.Millisecond)
+		client.HTTPClient.Transport = stallingTransport{}
+		tv := &TV{Name: "TV-1", IP: "192.168.1.100"}
+
+		Convey("It should give up reading the answer after the timeouts", func() {
+			start := time.Now()
+			_, err := client.Get3DState(context.Background(), tv)
+			So(err, ShouldNotBeNil)
+			So(ErrorCode(err), ShouldEqual, "timeout")
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+		})
+	})
+}
@@ -0,0 +1,202 @@
+package roap
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is how many TVs a Cluster works on at once
+const DefaultConcurrency = 16
+
+// Operation is the work a Cluster does on a single TV. It returns a short
+// description of the outcome, e.g. "3D on".
+type Operation func(ctx context.Context, tv *TV) (string, error)
+
+// Result is the outcome of an Operation on one TV
+type Result struct {
+	TV       *TV
+	Outcome  string
+	Err      error
+	Duration time.Duration
+	// Sent is set by RunSynchronized when a command was sent to the TV, and
+	// Skew is then how long after the barrier it went out
+	Sent bool
+	Skew time.Duration
+	// Retries is how many times Recover ran the operation again
+	Retries int
+	// RolledBack is set when Recover undid the operation on the TV, and
+	// RollbackErr when undoing it failed
+	RolledBack  bool
+	RollbackErr error
+}
+
+// Cluster runs an operation across a selection of TVs in parallel
+type Cluster struct {
+	// Registry, when set, is used to hold each TV while it is worked on
+	Registry *Registry
+	// Concurrency bounds how many TVs are worked on at once, DefaultConcurrency if zero
+	Concurrency int
+	// Timeout bounds the operation on each TV, no limit if zero
+	Timeout time.Duration
+	// Policy and Retries decide what Recover does about failed TVs
+	Policy  Policy
+	Retries int
+	// OnResult, when set, is called with the result of each TV as soon as
+	// it finishes, one call at a time, so results can be shown before the
+	// slowest TV is done. Recover doesn't call it for the TVs it revisits.
+	OnResult func(Result)
+}
+
+// Run applies op to every TV and returns the results in the order of tvs,
+// however the operations finish
+func (cl *Cluster) Run(ctx context.Context, tvs []*TV, op Operation) []Result {
+	return cl.run(ctx, tvs, op, cl.reporter())
+}
+
+// reporter returns a function passing results to OnResult one at a time, or
+// nil when there is no OnResult
+func (cl *Cluster) reporter() func(Result) {
+	if cl.OnResult == nil {
+		return nil
+	}
+	var mu sync.Mutex
+	return func(result Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		cl.OnResult(result)
+	}
+}
+
+// run is Run, reporting each result to report unless it is nil
+func (cl *Cluster) run(ctx context.Context, tvs []*TV, op Operation, report func(Result)) []Result {
+	concurrency := cl.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	results := make([]Result, len(tvs))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, tv := range tvs {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, tv *TV) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = cl.runOne(ctx, tv, op)
+			if report != nil {
+				report(results[i])
+			}
+		}(i, tv)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// RunSynchronized prepares every TV first, e.g. by authorizing a session, and
+// then releases op on all prepared TVs at the same instant, so a wall of TVs
+// changes together. TVs that fail to prepare are left out. Every TV is
+// released at once, whatever the Concurrency.
+func (cl *Cluster) RunSynchronized(ctx context.Context, tvs []*TV, prepare func(ctx context.Context, tv *TV) error, op Operation) []Result {
+	report := cl.reporter()
+	results := cl.run(ctx, tvs, func(ctx context.Context, tv *TV) (string, error) {
+		return "", prepare(ctx, tv)
+	}, nil)
+
+	barrier := make(chan struct{})
+	var released time.Time
+	var ready, done sync.WaitGroup
+
+	for i, tv := range tvs {
+		if results[i].Err != nil {
+			if report != nil {
+				report(results[i])
+			}
+			continue
+		}
+		ready.Add(1)
+		done.Add(1)
+		go func(i int, tv *TV) {
+			defer done.Done()
+			clock := &sendClock{}
+			ready.Done()
+			<-barrier
+
+			results[i] = cl.runOne(context.WithValue(ctx, sendClockKey{}, clock), tv, op)
+			if sent, ok := clock.get(); ok {
+				results[i].Sent = true
+				results[i].Skew = sent.Sub(released)
+			}
+			if report != nil {
+				report(results[i])
+			}
+		}(i, tv)
+	}
+
+	ready.Wait()
+	released = time.Now()
+	close(barrier)
+	done.Wait()
+	return results
+}
+
+// sendClockKey is the context key of the sendClock of a synchronized run
+type sendClockKey struct{}
+
+// sendClock records when the first command of an operation was sent
+type sendClock struct {
+	mu   sync.Mutex
+	sent time.Time
+}
+
+func (sc *sendClock) get() (time.Time, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.sent, !sc.sent.IsZero()
+}
+
+// markSent records the current time on the sendClock in ctx, if there is
+// one and it hasn't been set yet
+func markSent(ctx context.Context) {
+	sc, ok := ctx.Value(sendClockKey{}).(*sendClock)
+	if !ok {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.sent.IsZero() {
+		sc.sent = time.Now()
+	}
+}
+
+// runOne applies op to a single TV within the per TV timeout
+func (cl *Cluster) runOne(ctx context.Context, tv *TV, op Operation) Result {
+	if cl.Registry != nil {
+		release := cl.Registry.Acquire(tv)
+		defer release()
+	}
+
+	if cl.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cl.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	outcome, err := op(ctx, tv)
+	return Result{TV: tv, Outcome: outcome, Err: err, Duration: time.Since(start)}
+}
+
+// Failed returns the results that ended in an error
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
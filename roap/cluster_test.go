@@ -0,0 +1,158 @@
+package roap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCluster(t *testing.T) {
+	Convey("Given a cluster of TVs", t, func() {
+		config := &TVConfig{TVs: []TV{
+			{Name: "TV-1", IP: "192.168.1.100"},
+			{Name: "TV-2", IP: "192.168.1.101"},
+			{Name: "TV-3", IP: "192.168.1.102"},
+			{Name: "TV-4", IP: "192.168.1.103"},
+		}}
+		registry := NewRegistry(config)
+		tvs := registry.TVs()
+
+		Convey("It should return results in TV order however they finish", func() {
+			cluster := &Cluster{Registry: registry}
+			results := cluster.Run(context.Background(), tvs, func(ctx context.Context, tv *TV) (string, error) {
+				// the first TV is the slowest
+				time.Sleep(time.Duration(len(tvs)-int(tv.Name[3]-'0')) * 5 * time.Millisecond)
+				if tv.Name == "TV-3" {
+					return "", errors.New("powered off")
+				}
+				return "done " + tv.Name, nil
+			})
+
+			So(results, ShouldHaveLength, 4)
+			for i, result := range results {
+				So(result.TV, ShouldPointTo, tvs[i])
+			}
+			So(results[0].Outcome, ShouldEqual, "done TV-1")
+			So(results[0].Duration, ShouldBeGreaterThanOrEqualTo, 15*time.Millisecond)
+
+			failed := Failed(results)
+			So(failed, ShouldHaveLength, 1)
+			So(failed[0].TV.Name, ShouldEqual, "TV-3")
+		})
+
+		Convey("It should work on no more TVs at once than allowed", func() {
+			var mu sync.Mutex
+			running, maxRunning := 0, 0
+
+			cluster := &Cluster{Concurrency: 2}
+			cluster.Run(context.Background(), tvs, func(ctx context.Context, tv *TV) (string, error) {
+				mu.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return "", nil
+			})
+
+			So(maxRunning, ShouldEqual, 2)
+		})
+
+		Convey("It should give up on a TV after the per TV timeout", func() {
+			cluster := &Cluster{Timeout: 10 * time.Millisecond}
+			results := cluster.Run(context.Background(), tvs[:2], func(ctx context.Context, tv *TV) (string, error) {
+				if tv.Name == "TV-1" {
+					<-ctx.Done()
+					return "", ctx.Err()
+				}
+				return "done", nil
+			})
+
+			So(errors.Is(results[0].Err, context.DeadlineExceeded), ShouldBeTrue)
+			So(results[1].Err, ShouldBeNil)
+		})
+
+		Convey("It should release every prepared TV at once and leave out the rest", func() {
+			var mu sync.Mutex
+			prepared := 0
+			releasedEarly := false
+
+			cluster := &Cluster{Registry: registry, Concurrency: 1}
+			results := cluster.RunSynchronized(context.Background(), tvs, func(ctx context.Context, tv *TV) error {
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				defer mu.Unlock()
+				if tv.Name == "TV-2" {
+					return errors.New("not paired")
+				}
+				prepared++
+				return nil
+			}, func(ctx context.Context, tv *TV) (string, error) {
+				mu.Lock()
+				if prepared < 3 {
+					releasedEarly = true
+				}
+				mu.Unlock()
+				if tv.Name != "TV-4" {
+					markSent(ctx)
+				}
+				return "done", nil
+			})
+
+			So(releasedEarly, ShouldBeFalse)
+			So(results[0].Outcome, ShouldEqual, "done")
+			So(results[0].Sent, ShouldBeTrue)
+			So(results[0].Skew, ShouldBeGreaterThanOrEqualTo, 0)
+			So(results[1].Err, ShouldNotBeNil)
+			So(results[1].Outcome, ShouldEqual, "")
+			So(results[3].Err, ShouldBeNil)
+			So(results[3].Sent, ShouldBeFalse)
+		})
+
+		Convey("It should report each result as its TV finishes", func() {
+			var finished []string
+			cluster := &Cluster{OnResult: func(result Result) {
+				finished = append(finished, result.TV.Name)
+			}}
+			cluster.Run(context.Background(), tvs, func(ctx context.Context, tv *TV) (string, error) {
+				// the first TV is the slowest
+				time.Sleep(time.Duration(len(tvs)-int(tv.Name[3]-'0')) * 10 * time.Millisecond)
+				return "done", nil
+			})
+			So(finished, ShouldResemble, []string{"TV-4", "TV-3", "TV-2", "TV-1"})
+
+			finished = nil
+			cluster.RunSynchronized(context.Background(), tvs[:2], func(ctx context.Context, tv *TV) error {
+				if tv.Name == "TV-2" {
+					return errors.New("not paired")
+				}
+				return nil
+			}, func(ctx context.Context, tv *TV) (string, error) {
+				return "done", nil
+			})
+			So(finished, ShouldResemble, []string{"TV-2", "TV-1"})
+		})
+
+		Convey("It should not report the TVs Recover revisits", func() {
+			reported := 0
+			cluster := &Cluster{Policy: RetryFailed, Retries: 2, OnResult: func(result Result) {
+				reported++
+			}}
+			op := func(ctx context.Context, tv *TV) (string, error) {
+				return "", errors.New("powered off")
+			}
+			cluster.Recover(context.Background(), cluster.Run(context.Background(), tvs, op), op, nil)
+			So(reported, ShouldEqual, len(tvs))
+		})
+	})
+}
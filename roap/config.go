@@ -0,0 +1,325 @@
+package roap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TVConfig is a collection of TV records from the JSON configuration file
+type TVConfig struct {
+	TVs             []TV     `json:"tvs"`
+	ConnectTimeout  Duration `json:"connect_timeout"`
+	ReadTimeout     Duration `json:"read_timeout"`
+	StateCache      string   `json:"state_cache"`
+	StateCacheTTL   Duration `json:"state_cache_ttl"`
+	Enable3DDelay   Duration `json:"enable_3d_delay"`
+	Enable3DRetries *int     `json:"enable_3d_retries"`
+	StateTimeout    Duration `json:"state_timeout"`
+	// Retry replaces DefaultRetryPolicy, see RetryPolicy
+	Retry *RetryPolicy `json:"retry"`
+	// Groups name lists of TVs, see Select
+	Groups map[string][]string `json:"groups"`
+	// Macros are named key sequences, see ParseSequence
+	Macros map[string][]string `json:"macros"`
+}
+
+// Duration is a time.Duration read from JSON either as a string such as
+// "1.5s" or as a number of seconds
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		d.Duration = time.Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// ConfigFile returns the path of the JSON configuration file, taken from
+// LG_REMOTE_PATH and LG_REMOTE_CONFIG_FILE or defaulting to ./tv_config.json
+func ConfigFile() (string, error) {
+	configPath := os.Getenv("LG_REMOTE_PATH")
+	configFile := os.Getenv("LG_REMOTE_CONFIG_FILE")
+	if configFile != "" && configPath != "" {
+		return filepath.Join(configPath, configFile), nil
+	}
+
+	filename, err := filepath.Abs("./tv_config.json")
+	if err != nil {
+		return "", &ConfigError{Path: "./tv_config.json", Err: err}
+	}
+	return filename, nil
+}
+
+// LoadConfig reads the JSON configuration file at filename
+func LoadConfig(filename string) (*TVConfig, error) {
+	jsonFile, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, &ConfigError{Path: filename, Err: err}
+	}
+
+	var tvConfig TVConfig
+
+	err = json.Unmarshal(jsonFile, &tvConfig)
+	if err != nil {
+		return nil, &ConfigError{Path: filename, Err: err}
+	}
+	return &tvConfig, nil
+}
+
+// LoadTVs reads the TV records from the JSON configuration file at filename
+func LoadTVs(filename string) ([]TV, error) {
+	tvConfig, err := LoadConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+	return tvConfig.TVs, nil
+}
+
+// NewClient returns a Client using the timeouts, state cache and 3D settings
+// from the configuration, falling back to the package defaults
+func (config *TVConfig) NewClient() *Client {
+	connectTimeout := config.ConnectTimeout.Duration
+	if connectTimeout == 0 {
+		connectTimeout = DefaultConnectTimeout
+	}
+	readTimeout := config.ReadTimeout.Duration
+	if readTimeout == 0 {
+		readTimeout = DefaultReadTimeout
+	}
+	client := NewClientWithTimeouts(connectTimeout, readTimeout)
+	client.Macros = config.Macros
+
+	cachePath := config.StateCache
+	if cachePath == "" {
+		cachePath = DefaultStateCachePath()
+	}
+	cacheTTL := config.StateCacheTTL.Duration
+	if cacheTTL == 0 {
+		cacheTTL = DefaultStateCacheTTL
+	}
+	client.Cache = OpenStateCache(cachePath, cacheTTL)
+
+	if config.Enable3DDelay.Duration > 0 {
+		client.Enable3DDelay = config.Enable3DDelay.Duration
+	}
+	if config.Enable3DRetries != nil {
+		client.Enable3DRetries = *config.Enable3DRetries
+	}
+	if config.StateTimeout.Duration > 0 {
+		client.StateTimeout = config.StateTimeout.Duration
+	}
+	if config.Retry != nil {
+		client.Retry = *config.Retry
+	}
+	return client
+}
+
+// GetConfig builds the TVConfig from the JSON file
+func GetConfig() (*TVConfig, error) {
+	filename, err := ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return LoadConfig(filename)
+}
+
+// GetAllTVs builds the TVConfig (and TVs) from the JSON file
+func GetAllTVs() ([]TV, error) {
+	tvConfig, err := GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return tvConfig.TVs, nil
+}
+
+// SetPairingKey writes key as the pairing key of the TV called name in the
+// JSON configuration file at filename. Every other entry and field of the
+// file is kept as it is, in the same order.
+func SetPairingKey(filename string, name string, key string) error {
+	jsonFile, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return &ConfigError{Path: filename, Err: err}
+	}
+
+	document, err := parseObject(jsonFile)
+	if err != nil {
+		return &ConfigError{Path: filename, Err: err}
+	}
+	tvsKey, ok := document.lookup("tvs")
+	if !ok {
+		return &ConfigError{Path: filename, Err: fmt.Errorf("no tvs list")}
+	}
+	var rawTVs []json.RawMessage
+	if err := json.Unmarshal(document.values[tvsKey], &rawTVs); err != nil {
+		return &ConfigError{Path: filename, Err: err}
+	}
+
+	found := false
+	tvs := make([]*object, len(rawTVs))
+	for i, raw := range rawTVs {
+		if tvs[i], err = parseObject(raw); err != nil {
+			return &ConfigError{Path: filename, Err: err}
+		}
+		var tvName string
+		if nameKey, ok := tvs[i].lookup("name"); ok {
+			json.Unmarshal(tvs[i].values[nameKey], &tvName)
+		}
+		if tvName != name {
+			continue
+		}
+		keyKey, ok := tvs[i].lookup("key")
+		if !ok {
+			keyKey = "key"
+		}
+		value, _ := json.Marshal(key)
+		tvs[i].set(keyKey, value)
+		found = true
+	}
+	if !found {
+		return &ConfigError{Path: filename, Err: fmt.Errorf("no TV named %s", name)}
+	}
+
+	value, err := json.Marshal(tvs)
+	if err != nil {
+		return &ConfigError{Path: filename, Err: err}
+	}
+	document.set(tvsKey, value)
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return &ConfigError{Path: filename, Err: err}
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return &ConfigError{Path: filename, Err: err}
+	}
+	if err := writeFileAtomic(filename, append(data, '\n'), info.Mode().Perm()); err != nil {
+		return &ConfigError{Path: filename, Err: err}
+	}
+	return nil
+}
+
+// object is a JSON object that remembers the order of its keys, so a
+// rewritten config file only differs where it was changed
+type object struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+// parseObject reads the JSON object in data, keeping its values undecoded
+func parseObject(data []byte) (*object, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	obj := &object{values: map[string]json.RawMessage{}}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		obj.set(token.(string), value)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// lookup returns the key of obj matching name the way encoding/json does,
+// preferring an exact match and otherwise ignoring case
+func (obj *object) lookup(name string) (string, bool) {
+	if _, ok := obj.values[name]; ok {
+		return name, true
+	}
+	for _, key := range obj.keys {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// set replaces the value of key, adding it at the end if it is new
+func (obj *object) set(key string, value json.RawMessage) {
+	if _, ok := obj.values[key]; !ok {
+		obj.keys = append(obj.keys, key)
+	}
+	obj.values[key] = value
+}
+
+// MarshalJSON implements json.Marshaler, writing the keys in their order
+func (obj *object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range obj.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(obj.values[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic writes data to a temporary file next to filename and renames
+// it into place, so readers never see a partially written file
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
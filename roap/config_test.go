@@ -0,0 +1,121 @@
+package roap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConfig(t *testing.T) {
+	Convey("Given a TV Configuration file with timeouts", t, func() {
+		config, err := LoadConfig("testdata/tv_config.json")
+		Convey("It should parse durations as strings or seconds", func() {
+			So(err, ShouldBeNil)
+			So(config.ConnectTimeout.Duration, ShouldEqual, 3*time.Second)
+			So(config.ReadTimeout.Duration, ShouldEqual, 5*time.Second)
+			So(config.Enable3DDelay.Duration, ShouldEqual, time.Second)
+			So(config.TVs[1].Enable3DDelay.Duration, ShouldEqual, 1500*time.Millisecond)
+		})
+
+		Convey("It should give the client the connect timeout", func() {
+			So(err, ShouldBeNil)
+			So(config.NewClient().ConnectTimeout, ShouldEqual, 3*time.Second)
+			config.ConnectTimeout.Duration = 250 * time.Millisecond
+			So(config.NewClient().ConnectTimeout, ShouldEqual, 250*time.Millisecond)
+		})
+
+		Convey("It should read the global and per TV retry policies", func() {
+			So(err, ShouldBeNil)
+			So(config.NewClient().Retry, ShouldResemble, DefaultRetryPolicy)
+			So(config.TVs[0].Retry, ShouldBeNil)
+			So(config.TVs[1].Retry.MaxAttempts, ShouldEqual, 5)
+			So(config.TVs[1].Retry.Backoff.Duration, ShouldEqual, 500*time.Millisecond)
+		})
+	})
+
+	Convey("Given a missing TV Configuration file", t, func() {
+		tvs, err := LoadTVs("testdata/does_not_exist.json")
+		Convey("It should return a config error instead of panicking", func() {
+			So(tvs, ShouldBeNil)
+			So(err, ShouldHaveSameTypeAs, &ConfigError{})
+			So(err.Error(), ShouldContainSubstring, "testdata/does_not_exist.json")
+		})
+	})
+
+	Convey("Given a malformed TV Configuration file", t, func() {
+		tvs, err := LoadTVs("testdata/malformed_config.json")
+		Convey("It should return a config error instead of panicking", func() {
+			So(tvs, ShouldBeNil)
+			So(err, ShouldHaveSameTypeAs, &ConfigError{})
+			So(err.Error(), ShouldContainSubstring, "malformed_config.json")
+		})
+	})
+
+	Convey("Given a paired TV", t, func() {
+		dir, err := ioutil.TempDir("", "lg_remote")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		filename := filepath.Join(dir, "tv_config.json")
+		original := `{"read_timeout": "5s", "tvs": [{"name": "TV-1", "ip": "192.168.1.100", "key": "xyz123", "location": "left"}, {"name": "TV-2", "ip": "192.168.1.101", "key": ""}]}`
+		So(ioutil.WriteFile(filename, []byte(original), 0644), ShouldBeNil)
+
+		Convey("It should write the pairing key and keep every other entry", func() {
+			So(SetPairingKey(filename, "TV-2", "ABC123"), ShouldBeNil)
+
+			config, err := LoadConfig(filename)
+			So(err, ShouldBeNil)
+			So(config.ReadTimeout.Duration, ShouldEqual, 5*time.Second)
+			So(config.TVs, ShouldHaveLength, 2)
+			So(config.TVs[0].Key, ShouldEqual, "xyz123")
+			So(config.TVs[1].Key, ShouldEqual, "ABC123")
+
+			data, _ := ioutil.ReadFile(filename)
+			So(string(data), ShouldContainSubstring, `"location": "left"`)
+		})
+
+		Convey("It should keep the order of the keys", func() {
+			original := `{"tvs": [{"name": "TV-1", "ip": "192.168.1.100", "key": ""}], "read_timeout": "5s"}`
+			So(ioutil.WriteFile(filename, []byte(original), 0644), ShouldBeNil)
+			So(SetPairingKey(filename, "TV-1", "ABC123"), ShouldBeNil)
+
+			data, _ := ioutil.ReadFile(filename)
+			text := string(data)
+			So(strings.Index(text, `"tvs"`), ShouldBeLessThan, strings.Index(text, `"read_timeout"`))
+			So(strings.Index(text, `"name"`), ShouldBeLessThan, strings.Index(text, `"ip"`))
+		})
+
+		Convey("It should find the TVs list and fields whatever their case", func() {
+			original := `{"TVs": [{"Name": "TV-1", "IP": "192.168.1.100", "Key": ""}]}`
+			So(ioutil.WriteFile(filename, []byte(original), 0644), ShouldBeNil)
+			So(SetPairingKey(filename, "TV-1", "ABC123"), ShouldBeNil)
+
+			config, err := LoadConfig(filename)
+			So(err, ShouldBeNil)
+			So(config.TVs[0].Key, ShouldEqual, "ABC123")
+
+			data, _ := ioutil.ReadFile(filename)
+			So(string(data), ShouldContainSubstring, `"Key": "ABC123"`)
+			So(string(data), ShouldNotContainSubstring, `"key"`)
+		})
+
+		Convey("It should report a file without a TVs list", func() {
+			So(ioutil.WriteFile(filename, []byte(`{"read_timeout": "5s"}`), 0644), ShouldBeNil)
+			err := SetPairingKey(filename, "TV-1", "ABC123")
+			So(err, ShouldHaveSameTypeAs, &ConfigError{})
+			So(err.Error(), ShouldContainSubstring, "no tvs list")
+		})
+
+		Convey("It should refuse an unknown TV", func() {
+			So(SetPairingKey(filename, "TV-9", "ABC123"), ShouldHaveSameTypeAs, &ConfigError{})
+
+			data, _ := ioutil.ReadFile(filename)
+			So(string(data), ShouldEqual, original)
+		})
+	})
+}
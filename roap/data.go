@@ -0,0 +1,234 @@
+package roap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DataTargets lists the /data targets Query understands
+var DataTargets = []string{"cur_channel", "channel_list", "volume_info", "context_ui", "appnum", "applist", "is_3d"}
+
+// dataQueries holds the query string of targets that take parameters; type 1
+// asks for every installed app
+var dataQueries = map[string]string{
+	"appnum":  "appnum_get&type=1",
+	"applist": "applist_get&type=1&index=0&number=0",
+}
+
+// UnknownTargetError is returned by Query for a target not in DataTargets
+type UnknownTargetError struct {
+	Target string
+}
+
+func (e *UnknownTargetError) Error() string {
+	return fmt.Sprintf("roap: unknown data target %q, expected one of %s", e.Target, strings.Join(DataTargets, ", "))
+}
+
+// Channel is the channel a TV is tuned to, or an entry of its channel list
+type Channel struct {
+	Type            string `xml:"chtype" json:"type"`
+	SourceIndex     int    `xml:"sourceIndex" json:"source_index"`
+	PhysicalNum     int    `xml:"physicalNum" json:"physical_num"`
+	Major           int    `xml:"major" json:"major"`
+	Minor           int    `xml:"minor" json:"minor"`
+	Name            string `xml:"chname" json:"name"`
+	Program         string `xml:"progName" json:"program,omitempty"`
+	InputSourceName string `xml:"inputSourceName" json:"input_source_name,omitempty"`
+	InputSourceType int    `xml:"inputSourceType" json:"input_source_type"`
+	InputSourceIdx  int    `xml:"inputSourceIdx" json:"input_source_idx"`
+	LabelName       string `xml:"labelName" json:"label_name,omitempty"`
+}
+
+// Number returns the channel number as shown on screen, e.g. "11-1"
+func (ch *Channel) Number() string {
+	if ch.Minor > 0 {
+		return fmt.Sprintf("%d-%d", ch.Major, ch.Minor)
+	}
+	return fmt.Sprintf("%d", ch.Major)
+}
+
+func (ch Channel) String() string {
+	text := strings.TrimSpace(ch.Number() + " " + ch.Name)
+	if ch.InputSourceName != "" {
+		text += fmt.Sprintf(" (%s)", ch.InputSourceName)
+	}
+	return text
+}
+
+// VolumeInfo is the volume of a TV
+type VolumeInfo struct {
+	Mute  bool `xml:"mute" json:"mute"`
+	Min   int  `xml:"minLevel" json:"min"`
+	Max   int  `xml:"maxLevel" json:"max"`
+	Level int  `xml:"level" json:"level"`
+}
+
+func (v VolumeInfo) String() string {
+	text := fmt.Sprintf("volume %d of %d-%d", v.Level, v.Min, v.Max)
+	if v.Mute {
+		text += ", muted"
+	}
+	return text
+}
+
+// ContextUI is what the TV is showing, e.g. live TV or the app menu
+type ContextUI struct {
+	Mode          string `xml:"mode" json:"mode"`
+	CurrentWidget string `xml:"currentWidget" json:"current_widget,omitempty"`
+}
+
+func (ui ContextUI) String() string {
+	if ui.CurrentWidget != "" {
+		return fmt.Sprintf("%s, %s", ui.Mode, ui.CurrentWidget)
+	}
+	return ui.Mode
+}
+
+// App is an app installed on the TV
+type App struct {
+	AUID     string `xml:"auid" json:"auid"`
+	Name     string `xml:"name" json:"name"`
+	Type     int    `xml:"type" json:"type"`
+	CPID     string `xml:"cpid" json:"cpid,omitempty"`
+	Adult    string `xml:"adult" json:"adult,omitempty"`
+	IconName string `xml:"icon_name" json:"icon_name,omitempty"`
+}
+
+func (app App) String() string {
+	return fmt.Sprintf("%s (%s)", app.Name, app.AUID)
+}
+
+// getData reads a /data target of the TV, e.g. "cur_channel", into v
+func (c *Client) getData(ctx context.Context, tv *TV, target string, v interface{}) error {
+	query := target
+	if q, ok := dataQueries[target]; ok {
+		query = q
+	}
+	path := "/data?target=" + query
+	url := BuildURI(tv, path)
+	resp, err := c.do(ctx, tv, path, false, func() (*http.Request, error) {
+		return http.NewRequest("GET", url, nil)
+	})
+	if err != nil {
+		return err
+	}
+	return decodeResponse(path, resp, v)
+}
+
+// Query reads any of the DataTargets and returns it parsed: a *Channel for
+// cur_channel, []Channel for channel_list, *VolumeInfo, *ContextUI, the
+// number of apps for appnum, []App for applist and the 3D state for is_3d.
+// Nothing is sent to the TV that would change what it shows.
+func (c *Client) Query(ctx context.Context, tv *TV, target string) (interface{}, error) {
+	switch target {
+	case "cur_channel":
+		return c.CurrentChannel(ctx, tv)
+	case "channel_list":
+		return c.ChannelList(ctx, tv)
+	case "volume_info":
+		return c.Volume(ctx, tv)
+	case "context_ui":
+		return c.ContextUI(ctx, tv)
+	case "appnum":
+		return c.AppCount(ctx, tv)
+	case "applist":
+		return c.AppList(ctx, tv)
+	case "is_3d":
+		return c.Get3DState(ctx, tv)
+	}
+	return nil, &UnknownTargetError{Target: target}
+}
+
+// CurrentChannel returns the channel and input the TV is showing
+func (c *Client) CurrentChannel(ctx context.Context, tv *TV) (*Channel, error) {
+	var v struct {
+		Channel Channel `xml:"dataList>data"`
+	}
+	if err := c.getData(ctx, tv, "cur_channel", &v); err != nil {
+		return nil, err
+	}
+	return &v.Channel, nil
+}
+
+// ChannelList returns every channel the TV has tuned
+func (c *Client) ChannelList(ctx context.Context, tv *TV) ([]Channel, error) {
+	var v struct {
+		Channels []Channel `xml:"dataList>data"`
+	}
+	if err := c.getData(ctx, tv, "channel_list", &v); err != nil {
+		return nil, err
+	}
+	return v.Channels, nil
+}
+
+// Volume returns the volume of the TV
+func (c *Client) Volume(ctx context.Context, tv *TV) (*VolumeInfo, error) {
+	var v struct {
+		Volume VolumeInfo `xml:"dataList>data"`
+	}
+	if err := c.getData(ctx, tv, "volume_info", &v); err != nil {
+		return nil, err
+	}
+	return &v.Volume, nil
+}
+
+// ContextUI returns what the TV is showing
+func (c *Client) ContextUI(ctx context.Context, tv *TV) (*ContextUI, error) {
+	var v struct {
+		UI ContextUI `xml:"data"`
+	}
+	if err := c.getData(ctx, tv, "context_ui", &v); err != nil {
+		return nil, err
+	}
+	return &v.UI, nil
+}
+
+// AppCount returns how many apps are installed on the TV
+func (c *Client) AppCount(ctx context.Context, tv *TV) (int, error) {
+	var v struct {
+		Number int `xml:"data>number"`
+	}
+	if err := c.getData(ctx, tv, "appnum", &v); err != nil {
+		return 0, err
+	}
+	return v.Number, nil
+}
+
+// AppList returns the apps installed on the TV
+func (c *Client) AppList(ctx context.Context, tv *TV) ([]App, error) {
+	var v struct {
+		Apps []App `xml:"data"`
+	}
+	if err := c.getData(ctx, tv, "applist", &v); err != nil {
+		return nil, err
+	}
+	return v.Apps, nil
+}
+
+// Get3DState returns the 3D state of the TV: "on", "off", "no-response" when
+// the TV can't be reached or doesn't say, or "unknown"
+func (c *Client) Get3DState(ctx context.Context, tv *TV) (string, error) {
+	var v struct {
+		Is3D string `xml:"data>is3D"`
+	}
+	if err := c.getData(ctx, tv, "is_3d", &v); err != nil {
+		var transportErr *TransportError
+		if errors.As(err, &transportErr) && transportErr.StatusCode == 0 {
+			return "no-response", err
+		}
+		return "unknown", err
+	}
+
+	switch v.Is3D {
+	case "true":
+		return "on", nil
+	case "false":
+		return "off", nil
+	case "":
+		return "no-response", nil
+	}
+	return "unknown", nil
+}
@@ -0,0 +1,66 @@
+package roap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const contextUI = `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><data><mode>VolCh</mode><currentWidget>Live TV</currentWidget></data></envelope>`
+
+const appNum = `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><data><type>1</type><number>2</number></data></envelope>`
+
+const appList = `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><data><auid>00000000000112ae</auid><name>Netflix</name><type>2</type><cpid>netflix</cpid><adult>N</adult><icon_name>netflix.png</icon_name></data><data><auid>0000000000011c65</auid><name>YouTube</name><type>2</type><cpid>youtube</cpid><adult>N</adult><icon_name>youtube.png</icon_name></data></envelope>`
+
+func TestQuery(t *testing.T) {
+	Convey("Given a TV answering data queries", t, func() {
+		client := NewClient()
+		tv := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123"}
+		ctx := context.Background()
+
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=cur_channel", httpmock.NewStringResponder(200, curChannel))
+		httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=volume_info", httpmock.NewStringResponder(200, volumeInfo))
+		httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=context_ui", httpmock.NewStringResponder(200, contextUI))
+		httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=appnum_get&type=1", httpmock.NewStringResponder(200, appNum))
+		httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=applist_get&type=1&index=0&number=0", httpmock.NewStringResponder(200, appList))
+		httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, is3DOff))
+
+		Convey("It should parse each target into its struct", func() {
+			value, err := client.Query(ctx, tv, "cur_channel")
+			So(err, ShouldBeNil)
+			So(value.(*Channel).String(), ShouldEqual, "11-1 KQED-HD (TV)")
+
+			value, err = client.Query(ctx, tv, "volume_info")
+			So(err, ShouldBeNil)
+			So(*value.(*VolumeInfo), ShouldResemble, VolumeInfo{Mute: false, Min: 0, Max: 100, Level: 12})
+
+			value, err = client.Query(ctx, tv, "context_ui")
+			So(err, ShouldBeNil)
+			So(value.(*ContextUI).Mode, ShouldEqual, "VolCh")
+
+			value, err = client.Query(ctx, tv, "appnum")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 2)
+
+			value, err = client.Query(ctx, tv, "applist")
+			So(err, ShouldBeNil)
+			apps := value.([]App)
+			So(apps, ShouldHaveLength, 2)
+			So(apps[1], ShouldResemble, App{AUID: "0000000000011c65", Name: "YouTube", Type: 2, CPID: "youtube", Adult: "N", IconName: "youtube.png"})
+
+			value, err = client.Query(ctx, tv, "is_3d")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "off")
+		})
+
+		Convey("It should refuse a target it doesn't know", func() {
+			_, err := client.Query(ctx, tv, "everything")
+			So(err, ShouldHaveSameTypeAs, &UnknownTargetError{})
+			So(httpmock.GetTotalCallCount(), ShouldEqual, 0)
+		})
+	})
+}
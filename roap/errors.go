@@ -0,0 +1,198 @@
+package roap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrNoPairingKey is returned when a session is requested for a TV without a pairing key
+var ErrNoPairingKey = errors.New("roap: no pairing key, set key first")
+
+// ErrNoSelector is returned by Select when no TVs were named at all
+var ErrNoSelector = errors.New("roap: no TV selector given, name a TV, group, tag:name, pattern or all")
+
+// ErrNoText is returned by TypeText when there is nothing to type
+var ErrNoText = errors.New("roap: no text to type")
+
+// ROAPError is returned when the TV answers with anything other than 200 OK
+// in the ROAPError and ROAPErrorDetail fields of the response envelope
+type ROAPError struct {
+	Code   int
+	Detail string
+}
+
+func (e *ROAPError) Error() string {
+	return fmt.Sprintf("roap: ROAPError %d %s", e.Code, e.Detail)
+}
+
+// TransportError is returned when the TV could not be reached, or answered
+// with an unexpected HTTP status
+type TransportError struct {
+	Path       string
+	StatusCode int
+	Err        error
+}
+
+func (e *TransportError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("roap: %s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("roap: %s: unexpected HTTP status %d", e.Path, e.StatusCode)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is returned when a response from the TV could not be decoded
+type ParseError struct {
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("roap: %s: could not parse response: %v", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// IsUnauthorized reports whether err is a ROAP 401, i.e. the pairing key or
+// session was rejected by the TV
+func IsUnauthorized(err error) bool {
+	var roapErr *ROAPError
+	return errors.As(err, &roapErr) && roapErr.Code == 401
+}
+
+// ConfigError is returned when the TV configuration file is missing or malformed
+type ConfigError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("roap: could not load TV config %s: %v", e.Path, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// StateError is returned when a TV doesn't reach the requested state
+type StateError struct {
+	Want string
+	Got  string
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("roap: TV did not reach 3D state %s, it reports %s", e.Want, e.Got)
+}
+
+// SelectorError is returned for a selector term that can't be used, such as
+// a group containing itself or a malformed pattern
+type SelectorError struct {
+	Term string
+	Err  error
+}
+
+func (e *SelectorError) Error() string {
+	return fmt.Sprintf("roap: bad selector %s: %v", e.Term, e.Err)
+}
+
+func (e *SelectorError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownStateError is returned when a TV doesn't say whether it is in 3D, so
+// pressing the 3D key, which toggles, could just as well switch it the wrong way
+type UnknownStateError struct {
+	State string
+}
+
+func (e *UnknownStateError) Error() string {
+	return fmt.Sprintf("roap: TV reports 3D state %s, not sending keys", e.State)
+}
+
+// UnknownTVError is returned when a selector matches no configured TV
+type UnknownTVError struct {
+	Name string
+}
+
+func (e *UnknownTVError) Error() string {
+	return fmt.Sprintf("roap: couldn't find tv %s", e.Name)
+}
+
+// ErrorCode returns a short, stable name for the kind of err, e.g.
+// "unreachable" or "unauthorized", for scripts reading the tool's output
+func ErrorCode(err error) string {
+	var (
+		roapErr      *ROAPError
+		transportErr *TransportError
+		parseErr     *ParseError
+		configErr    *ConfigError
+		stateErr     *StateError
+		unknownState *UnknownStateError
+		volumeErr    *VolumeError
+		unknownTV    *UnknownTVError
+		selectorErr  *SelectorError
+		unknownKey   *UnknownKeyError
+		unknownMacro *UnknownMacroError
+		unknownData  *UnknownTargetError
+		unknownCh    *UnknownChannelError
+		unknownApp   *UnknownAppError
+		ambiguousApp *AmbiguousAppError
+		netErr       net.Error
+	)
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrNoPairingKey):
+		return "no_pairing_key"
+	case errors.Is(err, ErrNoSelector):
+		return "no_selector"
+	case IsUnauthorized(err):
+		return "unauthorized"
+	case errors.As(err, &roapErr):
+		return "roap_error"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &transportErr):
+		if transportErr.StatusCode != 0 {
+			return "http_status"
+		}
+		return "unreachable"
+	case errors.As(err, &parseErr):
+		return "bad_response"
+	case errors.As(err, &configErr):
+		return "config"
+	case errors.As(err, &stateErr):
+		return "state_mismatch"
+	case errors.As(err, &unknownState):
+		return "unknown_state"
+	case errors.As(err, &volumeErr):
+		return "volume_mismatch"
+	case errors.As(err, &unknownTV):
+		return "unknown_tv"
+	case errors.As(err, &selectorErr):
+		return "bad_selector"
+	case errors.As(err, &unknownKey):
+		return "unknown_key"
+	case errors.As(err, &unknownMacro):
+		return "unknown_macro"
+	case errors.As(err, &unknownData):
+		return "unknown_target"
+	case errors.As(err, &unknownCh):
+		return "unknown_channel"
+	case errors.As(err, &unknownApp):
+		return "unknown_app"
+	case errors.As(err, &ambiguousApp):
+		return "ambiguous_app"
+	}
+	return "error"
+}
@@ -0,0 +1,34 @@
+package roap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrorCode(t *testing.T) {
+	Convey("Given errors returned by the client", t, func() {
+		Convey("It should name each kind of error", func() {
+			So(ErrorCode(nil), ShouldEqual, "")
+			So(ErrorCode(ErrNoPairingKey), ShouldEqual, "no_pairing_key")
+			So(ErrorCode(ErrNoSelector), ShouldEqual, "no_selector")
+			So(ErrorCode(&ROAPError{Code: 401, Detail: "Unauthorized"}), ShouldEqual, "unauthorized")
+			So(ErrorCode(&ROAPError{Code: 400, Detail: "bad request"}), ShouldEqual, "roap_error")
+			So(ErrorCode(&TransportError{Path: "/auth", Err: syscall.ECONNREFUSED}), ShouldEqual, "unreachable")
+			So(ErrorCode(&TransportError{Path: "/auth", StatusCode: 500}), ShouldEqual, "http_status")
+			So(ErrorCode(&TransportError{Path: "/auth", Err: context.DeadlineExceeded}), ShouldEqual, "timeout")
+			So(ErrorCode(&ParseError{Path: "/auth", Err: errors.New("EOF")}), ShouldEqual, "bad_response")
+			So(ErrorCode(&ConfigError{Path: "tv_config.json"}), ShouldEqual, "config")
+			So(ErrorCode(&StateError{Want: "on", Got: "off"}), ShouldEqual, "state_mismatch")
+			So(ErrorCode(&UnknownStateError{State: "no-response"}), ShouldEqual, "unknown_state")
+			So(ErrorCode(fmt.Errorf("front-wall: %w", &UnknownTVError{Name: "TV-9"})), ShouldEqual, "unknown_tv")
+			So(ErrorCode(&SelectorError{Term: "loop", Err: errors.New("the group contains itself")}), ShouldEqual, "bad_selector")
+			So(ErrorCode(&UnknownKeyError{Name: "NOPE"}), ShouldEqual, "unknown_key")
+			So(ErrorCode(errors.New("something else")), ShouldEqual, "error")
+		})
+	})
+}
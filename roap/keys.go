@@ -0,0 +1,135 @@
+package roap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Key is a remote control button and the code sent for it in HandleKeyInput
+type Key struct {
+	Name string
+	Code int
+}
+
+// Codes of the keys the client presses itself
+const (
+	// powerCode is the code of the POWER key, which toggles the TV on and off
+	powerCode      = "1"
+	volumeUpCode   = "24"
+	volumeDownCode = "25"
+	// muteCode toggles mute
+	muteCode = "26"
+	// threeDCode toggles 3D
+	threeDCode = "400"
+)
+
+// toggleCodes are the keys that flip a setting, so pressing one twice undoes
+// it: POWER, MUTE, PIP, 3D and 3D_LR
+var toggleCodes = map[string]bool{
+	powerCode:  true,
+	muteCode:   true,
+	"48":       true,
+	threeDCode: true,
+	"401":      true,
+}
+
+// IsToggle reports whether the key code flips a setting, such as POWER or 3D,
+// and so must not be sent again when the TV may have acted on it already
+func IsToggle(code string) bool {
+	return toggleCodes[code]
+}
+
+// Keys lists the buttons of the LG remote in the order they are documented
+var Keys = []Key{
+	{"POWER", 1},
+	{"NUM_0", 2},
+	{"NUM_1", 3},
+	{"NUM_2", 4},
+	{"NUM_3", 5},
+	{"NUM_4", 6},
+	{"NUM_5", 7},
+	{"NUM_6", 8},
+	{"NUM_7", 9},
+	{"NUM_8", 10},
+	{"NUM_9", 11},
+	{"UP", 12},
+	{"DOWN", 13},
+	{"LEFT", 14},
+	{"RIGHT", 15},
+	{"OK", 20},
+	{"HOME", 21},
+	{"MENU", 22},
+	{"BACK", 23},
+	{"VOL_UP", 24},
+	{"VOL_DOWN", 25},
+	{"MUTE", 26},
+	{"CH_UP", 27},
+	{"CH_DOWN", 28},
+	{"BLUE", 29},
+	{"GREEN", 30},
+	{"RED", 31},
+	{"YELLOW", 32},
+	{"PLAY", 33},
+	{"PAUSE", 34},
+	{"STOP", 35},
+	{"FAST_FORWARD", 36},
+	{"REWIND", 37},
+	{"SKIP_FORWARD", 38},
+	{"SKIP_BACKWARD", 39},
+	{"RECORD", 40},
+	{"RECORDING_LIST", 41},
+	{"LIVE_TV", 43},
+	{"EPG", 44},
+	{"INFO", 45},
+	{"ASPECT", 46},
+	{"INPUT", 47},
+	{"PIP", 48},
+	{"SUBTITLE", 49},
+	{"PROGRAM_LIST", 50},
+	{"TEXT", 51},
+	{"MARK", 52},
+	{"3D", 400},
+	{"3D_LR", 401},
+	{"DASH", 402},
+	{"PREVIOUS_CHANNEL", 403},
+	{"FAVORITE_CHANNEL", 404},
+	{"QUICK_MENU", 405},
+	{"TEXT_OPTION", 406},
+	{"AUDIO_DESCRIPTION", 407},
+	{"NETCAST", 408},
+	{"ENERGY_SAVING", 409},
+	{"AV_MODE", 410},
+	{"SIMPLINK", 411},
+	{"EXIT", 412},
+	{"RESERVATION_LIST", 413},
+	{"PIP_CH_UP", 414},
+	{"PIP_CH_DOWN", 415},
+	{"PIP_SWITCH", 416},
+	{"APPS", 417},
+}
+
+// UnknownKeyError is returned for a key name that isn't in Keys
+type UnknownKeyError struct {
+	Name string
+}
+
+func (e *UnknownKeyError) Error() string {
+	return fmt.Sprintf("roap: unknown key %q, run `keys` for the list", e.Name)
+}
+
+// LookupKey returns the HandleKeyInput code for a key name such as "OK" or
+// "vol_up". Raw numeric codes such as "400" are passed through unchanged.
+func LookupKey(name string) (string, error) {
+	if code, err := strconv.Atoi(name); err == nil && code > 0 {
+		return name, nil
+	}
+
+	normalized := strings.Replace(strings.ToUpper(name), "-", "_", -1)
+	for _, key := range Keys {
+		if key.Name == normalized {
+			return strconv.Itoa(key.Code), nil
+		}
+	}
+	return "", &UnknownKeyError{Name: name}
+}
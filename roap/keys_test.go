@@ -0,0 +1,56 @@
+package roap
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKeys(t *testing.T) {
+	Convey("Given a key name", t, func() {
+		Convey("It should return its code", func() {
+			code, err := LookupKey("OK")
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, "20")
+
+			code, _ = LookupKey("3D")
+			So(code, ShouldEqual, "400")
+
+			code, _ = LookupKey("NUM_5")
+			So(code, ShouldEqual, "7")
+		})
+
+		Convey("It should ignore case and accept dashes", func() {
+			code, err := LookupKey("vol-up")
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, "24")
+		})
+
+		Convey("It should pass raw codes through", func() {
+			code, err := LookupKey("412")
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, "412")
+		})
+
+		Convey("It should reject unknown names", func() {
+			_, err := LookupKey("VOLUME_TO_ELEVEN")
+			So(err, ShouldHaveSameTypeAs, &UnknownKeyError{})
+
+			_, err = LookupKey("-1")
+			So(err, ShouldHaveSameTypeAs, &UnknownKeyError{})
+		})
+	})
+
+	Convey("Given the key table", t, func() {
+		Convey("It should not repeat names or codes", func() {
+			names := map[string]bool{}
+			codes := map[int]bool{}
+			for _, key := range Keys {
+				So(names[key.Name], ShouldBeFalse)
+				So(codes[key.Code], ShouldBeFalse)
+				names[key.Name] = true
+				codes[key.Code] = true
+			}
+		})
+	})
+}
@@ -0,0 +1,123 @@
+package roap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Step is one key press of a sequence, followed by a pause
+type Step struct {
+	Code  string
+	Delay time.Duration
+}
+
+// BuiltinMacros are the key sequences used by the client itself. Macros of
+// the same name in the configuration file take precedence, both for the
+// macro command and for the client, see Client.Macros.
+var BuiltinMacros = map[string][]string{
+	"enable-3D": {"3D", "EXIT"},
+}
+
+// UnknownMacroError is returned for a macro name that isn't configured
+type UnknownMacroError struct {
+	Name string
+}
+
+func (e *UnknownMacroError) Error() string {
+	return fmt.Sprintf("roap: unknown macro %q", e.Name)
+}
+
+// ParseSequence turns tokens such as ["3D", "1s", "RIGHT", "RIGHT", "OK"]
+// into steps. Keys are resolved with LookupKey, and a duration sets the pause
+// after the key before it; keys without one are followed by defaultDelay.
+func ParseSequence(tokens []string, defaultDelay time.Duration) ([]Step, error) {
+	var steps []Step
+	for _, token := range tokens {
+		if _, err := strconv.Atoi(token); err != nil {
+			if delay, err := time.ParseDuration(token); err == nil {
+				if len(steps) == 0 {
+					return nil, fmt.Errorf("roap: sequence starts with a delay %q, expected a key", token)
+				}
+				steps[len(steps)-1].Delay = delay
+				continue
+			}
+		}
+
+		code, err := LookupKey(token)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, Step{Code: code, Delay: defaultDelay})
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("roap: empty key sequence")
+	}
+	return steps, nil
+}
+
+// LookupMacro returns the key sequence of the named macro, from the
+// configuration file or BuiltinMacros
+func (config *TVConfig) LookupMacro(name string) ([]string, error) {
+	return lookupMacro(config.Macros, name)
+}
+
+// lookupMacro returns the key sequence of the named macro, from macros or
+// BuiltinMacros
+func lookupMacro(macros map[string][]string, name string) ([]string, error) {
+	if tokens, ok := macros[name]; ok {
+		return tokens, nil
+	}
+	if tokens, ok := BuiltinMacros[name]; ok {
+		return tokens, nil
+	}
+	return nil, &UnknownMacroError{Name: name}
+}
+
+// MacroNames lists every configured and built-in macro, sorted
+func (config *TVConfig) MacroNames() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, macros := range []map[string][]string{config.Macros, BuiltinMacros} {
+		for name := range macros {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SendSequence sends each step to the TV in turn, pausing between keys. It
+// stops at the first key that fails.
+func (c *Client) SendSequence(ctx context.Context, tv *TV, steps []Step) error {
+	for i, step := range steps {
+		if err := c.SendCommand(ctx, tv, step.Code); err != nil {
+			return err
+		}
+		if i == len(steps)-1 {
+			continue
+		}
+		if err := sleep(ctx, step.Delay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sleep pauses for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
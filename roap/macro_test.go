@@ -0,0 +1,109 @@
+package roap
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMacros(t *testing.T) {
+	Convey("Given a key sequence", t, func() {
+		Convey("It should resolve keys and attach delays to the key before them", func() {
+			steps, err := ParseSequence([]string{"3D", "1s", "RIGHT", "RIGHT", "OK"}, 200*time.Millisecond)
+			So(err, ShouldBeNil)
+			So(steps, ShouldResemble, []Step{
+				{Code: "400", Delay: time.Second},
+				{Code: "15", Delay: 200 * time.Millisecond},
+				{Code: "15", Delay: 200 * time.Millisecond},
+				{Code: "20", Delay: 200 * time.Millisecond},
+			})
+		})
+
+		Convey("It should treat plain numbers as key codes, not delays", func() {
+			steps, err := ParseSequence([]string{"400", "20", "412"}, 0)
+			So(err, ShouldBeNil)
+			So(steps, ShouldHaveLength, 3)
+			So(steps[1].Code, ShouldEqual, "20")
+			So(steps[1].Delay, ShouldEqual, 0)
+		})
+
+		Convey("It should reject unknown keys, leading delays and empty sequences", func() {
+			_, err := ParseSequence([]string{"3D", "SIDEWAYS"}, 0)
+			So(err, ShouldHaveSameTypeAs, &UnknownKeyError{})
+
+			_, err = ParseSequence([]string{"1s", "OK"}, 0)
+			So(err, ShouldNotBeNil)
+
+			_, err = ParseSequence(nil, 0)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a configuration with macros", t, func() {
+		config := &TVConfig{Macros: map[string][]string{
+			"3d-menu-right": {"3D", "RIGHT", "RIGHT", "OK"},
+			"enable-3D":     {"3D", "2s", "OK"},
+		}}
+
+		Convey("It should prefer configured macros over built-in ones", func() {
+			tokens, err := config.LookupMacro("enable-3D")
+			So(err, ShouldBeNil)
+			So(tokens, ShouldResemble, []string{"3D", "2s", "OK"})
+
+			_, err = config.LookupMacro("power-nap")
+			So(err, ShouldHaveSameTypeAs, &UnknownMacroError{})
+
+			So(config.MacroNames(), ShouldResemble, []string{"3d-menu-right", "enable-3D"})
+		})
+
+		Convey("It should send every key of the macro in order", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			success := `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail></envelope>`
+			value := regexp.MustCompile(`<value>(\d+)</value>`)
+
+			var sent []string
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", func(req *http.Request) (*http.Response, error) {
+				body, _ := ioutil.ReadAll(req.Body)
+				sent = append(sent, value.FindStringSubmatch(string(body))[1])
+				return httpmock.NewStringResponse(200, success), nil
+			})
+
+			tokens, _ := config.LookupMacro("3d-menu-right")
+			steps, _ := ParseSequence(tokens, time.Millisecond)
+			tv := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123", Session: "1051689385"}
+
+			So(NewClient().SendSequence(context.Background(), tv, steps), ShouldBeNil)
+			So(sent, ShouldResemble, []string{"400", "15", "15", "20"})
+		})
+
+		Convey("It should enable 3D with the configured enable-3D macro", func() {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+			success := `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail></envelope>`
+			value := regexp.MustCompile(`<value>(\d+)</value>`)
+
+			var sent []string
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", func(req *http.Request) (*http.Response, error) {
+				body, _ := ioutil.ReadAll(req.Body)
+				sent = append(sent, value.FindStringSubmatch(string(body))[1])
+				return httpmock.NewStringResponse(200, success), nil
+			})
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", replies(is3DOff, is3DOn))
+
+			client := NewClient()
+			client.Enable3DDelay = time.Millisecond
+			client.Macros = map[string][]string{"enable-3D": {"3D", "RIGHT", "OK"}}
+			tv := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123", Session: "1051689385"}
+
+			So(client.Enable3D(context.Background(), tv), ShouldBeNil)
+			So(sent, ShouldResemble, []string{"400", "15", "20"})
+		})
+	})
+}
@@ -0,0 +1,119 @@
+package roap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Policy decides what a Cluster does about TVs on which an operation failed
+type Policy int
+
+const (
+	// BestEffort leaves every TV as the operation left it
+	BestEffort Policy = iota
+	// AllOrNothing undoes the operation on every TV if it failed on any
+	AllOrNothing
+	// RetryFailed runs the operation again on the TVs it failed on, up to
+	// the cluster's Retries times
+	RetryFailed
+)
+
+// DefaultRetries is how many times RetryFailed retries a TV unless told otherwise
+const DefaultRetries = 2
+
+var policyNames = map[Policy]string{
+	BestEffort:   "best-effort",
+	AllOrNothing: "all-or-nothing",
+	RetryFailed:  "retry-failed",
+}
+
+func (p Policy) String() string {
+	if name, ok := policyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// ParsePolicy returns the policy called name, e.g. "all-or-nothing"
+func ParsePolicy(name string) (Policy, error) {
+	for policy, policyName := range policyNames {
+		if policyName == name {
+			return policy, nil
+		}
+	}
+	return BestEffort, fmt.Errorf("roap: unknown failure policy %q, expected best-effort, all-or-nothing or retry-failed", name)
+}
+
+// Undo reverts an Operation on one TV and reports whether there was anything
+// to revert, e.g. Disable3D on a TV that Enable3D actually switched
+type Undo func(ctx context.Context, tv *TV) (bool, error)
+
+// Recover applies the cluster's Policy to the results of Run or
+// RunSynchronized. RetryFailed runs retry on the failed TVs, one round
+// at a time and without a barrier. AllOrNothing calls undo on every TV once
+// any has failed and marks those that were rolled back. Operations that must
+// not be repeated, such as toggles, pass a nil retry and those that can't be
+// undone a nil undo, which leaves the results as they are.
+func (cl *Cluster) Recover(ctx context.Context, results []Result, retry Operation, undo Undo) []Result {
+	switch cl.Policy {
+	case RetryFailed:
+		if retry == nil {
+			return results
+		}
+		for round := 1; round <= cl.Retries; round++ {
+			var failed []int
+			var tvs []*TV
+			for i, result := range results {
+				if result.Err != nil {
+					failed = append(failed, i)
+					tvs = append(tvs, result.TV)
+				}
+			}
+			if len(failed) == 0 {
+				break
+			}
+
+			retried := cl.run(ctx, tvs, retry, nil)
+			for j, i := range failed {
+				results[i] = retried[j]
+				results[i].Retries = round
+			}
+		}
+
+	case AllOrNothing:
+		if undo == nil || len(Failed(results)) == 0 {
+			return results
+		}
+
+		var mu sync.Mutex
+		undone := map[*TV]bool{}
+		tvs := make([]*TV, len(results))
+		for i, result := range results {
+			tvs[i] = result.TV
+		}
+		rollbacks := cl.run(ctx, tvs, func(ctx context.Context, tv *TV) (string, error) {
+			ok, err := undo(ctx, tv)
+			mu.Lock()
+			undone[tv] = ok
+			mu.Unlock()
+			return "", err
+		}, nil)
+		for i := range results {
+			results[i].RolledBack = undone[results[i].TV] && rollbacks[i].Err == nil
+			results[i].RollbackErr = rollbacks[i].Err
+		}
+	}
+	return results
+}
+
+// RolledBack returns the results of the TVs that AllOrNothing rolled back
+func RolledBack(results []Result) []Result {
+	var rolledBack []Result
+	for _, result := range results {
+		if result.RolledBack {
+			rolledBack = append(rolledBack, result)
+		}
+	}
+	return rolledBack
+}
@@ -0,0 +1,109 @@
+package roap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPolicy(t *testing.T) {
+	Convey("Given a cluster of TVs", t, func() {
+		config := &TVConfig{TVs: []TV{
+			{Name: "TV-1", IP: "192.168.1.100"},
+			{Name: "TV-2", IP: "192.168.1.101"},
+			{Name: "TV-3", IP: "192.168.1.102"},
+		}}
+		tvs := NewRegistry(config).TVs()
+
+		var mu sync.Mutex
+		attempts := map[string]int{}
+		// TV-2 fails twice before it works
+		op := func(ctx context.Context, tv *TV) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts[tv.Name]++
+			if tv.Name == "TV-2" && attempts[tv.Name] < 3 {
+				return "", errors.New("connection refused")
+			}
+			return "done", nil
+		}
+
+		Convey("It should parse policy names", func() {
+			for _, policy := range []Policy{BestEffort, AllOrNothing, RetryFailed} {
+				parsed, err := ParsePolicy(policy.String())
+				So(err, ShouldBeNil)
+				So(parsed, ShouldEqual, policy)
+			}
+			_, err := ParsePolicy("sometimes")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Best effort should leave failures alone", func() {
+			cluster := &Cluster{}
+			results := cluster.Recover(context.Background(), cluster.Run(context.Background(), tvs, op), op, nil)
+			So(results[1].Err, ShouldNotBeNil)
+			So(attempts["TV-2"], ShouldEqual, 1)
+		})
+
+		Convey("Retry failed should only run the failed TVs again", func() {
+			cluster := &Cluster{Policy: RetryFailed, Retries: 3}
+			results := cluster.Recover(context.Background(), cluster.Run(context.Background(), tvs, op), op, nil)
+
+			So(Failed(results), ShouldBeEmpty)
+			So(results[1].Retries, ShouldEqual, 2)
+			So(attempts, ShouldResemble, map[string]int{"TV-1": 1, "TV-2": 3, "TV-3": 1})
+		})
+
+		Convey("Retry failed should leave operations without a retry alone", func() {
+			cluster := &Cluster{Policy: RetryFailed, Retries: 3}
+			results := cluster.Recover(context.Background(), cluster.Run(context.Background(), tvs, op), nil, nil)
+
+			So(Failed(results), ShouldHaveLength, 1)
+			So(results[1].Retries, ShouldEqual, 0)
+			So(attempts["TV-2"], ShouldEqual, 1)
+		})
+
+		Convey("Retry failed should give up after the allowed retries", func() {
+			cluster := &Cluster{Policy: RetryFailed, Retries: 1}
+			results := cluster.Recover(context.Background(), cluster.Run(context.Background(), tvs, op), op, nil)
+
+			So(Failed(results), ShouldHaveLength, 1)
+			So(results[1].Retries, ShouldEqual, 1)
+		})
+
+		Convey("All or nothing should roll back the TVs that changed", func() {
+			cluster := &Cluster{Policy: AllOrNothing}
+			undo := func(ctx context.Context, tv *TV) (bool, error) {
+				switch tv.Name {
+				case "TV-1":
+					return true, nil
+				case "TV-3":
+					return true, errors.New("timeout")
+				}
+				return false, nil
+			}
+			results := cluster.Recover(context.Background(), cluster.Run(context.Background(), tvs, op), op, undo)
+
+			rolledBack := RolledBack(results)
+			So(rolledBack, ShouldHaveLength, 1)
+			So(rolledBack[0].TV.Name, ShouldEqual, "TV-1")
+			So(results[1].RolledBack, ShouldBeFalse)
+			So(results[2].RollbackErr, ShouldNotBeNil)
+		})
+
+		Convey("All or nothing should do nothing when every TV succeeded", func() {
+			cluster := &Cluster{Policy: AllOrNothing}
+			undone := false
+			results := cluster.Recover(context.Background(), cluster.Run(context.Background(), tvs[:1], op), op, func(ctx context.Context, tv *TV) (bool, error) {
+				undone = true
+				return true, nil
+			})
+
+			So(undone, ShouldBeFalse)
+			So(RolledBack(results), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,68 @@
+package roap
+
+import "sync"
+
+// Registry owns the TV records of a configuration. Lookups return pointers
+// to the records themselves, so sessions and 3D state found by the Client
+// are kept for the rest of the run.
+//
+// The registry is safe for concurrent use. A TV record itself is not, so
+// goroutines working on the same TV should hold it with Acquire; Select
+// never returns a TV twice, so one goroutine per selected TV is safe too.
+type Registry struct {
+	mu     sync.RWMutex
+	tvs    []*TV
+	groups map[string][]string
+	locks  map[*TV]*sync.Mutex
+}
+
+// NewRegistry builds a registry over the TVs and groups of config. The
+// registry shares the records in config.TVs rather than copying them.
+func NewRegistry(config *TVConfig) *Registry {
+	r := &Registry{
+		groups: config.Groups,
+		locks:  map[*TV]*sync.Mutex{},
+	}
+	for i := range config.TVs {
+		tv := &config.TVs[i]
+		r.tvs = append(r.tvs, tv)
+		r.locks[tv] = &sync.Mutex{}
+	}
+	return r
+}
+
+// Lookup returns the TV called name
+func (r *Registry) Lookup(name string) (*TV, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, tv := range r.tvs {
+		if tv.Name == name {
+			return tv, nil
+		}
+	}
+	return nil, &UnknownTVError{Name: name}
+}
+
+// TVs returns every TV in configuration order
+func (r *Registry) TVs() []*TV {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]*TV(nil), r.tvs...)
+}
+
+// Acquire waits until no other goroutine holds tv and returns the function
+// that releases it
+func (r *Registry) Acquire(tv *TV) (release func()) {
+	r.mu.RLock()
+	lock, ok := r.locks[tv]
+	r.mu.RUnlock()
+	if !ok {
+		// not one of ours, nobody else can be holding it through the registry
+		return func() {}
+	}
+
+	lock.Lock()
+	return lock.Unlock
+}
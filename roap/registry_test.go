@@ -0,0 +1,71 @@
+package roap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistry(t *testing.T) {
+	Convey("Given a registry built from a configuration", t, func() {
+		config := &TVConfig{TVs: []TV{
+			{Name: "TV-1", IP: "192.168.1.100"},
+			{Name: "TV-2", IP: "192.168.1.101"},
+		}}
+		registry := NewRegistry(config)
+
+		Convey("It should look up the real TV records", func() {
+			tv, err := registry.Lookup("TV-2")
+			So(err, ShouldBeNil)
+			tv.Current3DState = "on"
+			So(config.TVs[1].Current3DState, ShouldEqual, "on")
+
+			again, _ := registry.Lookup("TV-2")
+			So(again, ShouldPointTo, tv)
+
+			selected, _ := registry.Select("TV-2")
+			So(selected[0], ShouldPointTo, tv)
+		})
+
+		Convey("It should report unknown TVs", func() {
+			tv, err := registry.Lookup("TV-9")
+			So(tv, ShouldBeNil)
+			So(err, ShouldHaveSameTypeAs, &UnknownTVError{})
+		})
+
+		Convey("It should hand a TV to one goroutine at a time", func() {
+			tv, _ := registry.Lookup("TV-1")
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			holders, maxHolders := 0, 0
+
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					release := registry.Acquire(tv)
+					defer release()
+
+					mu.Lock()
+					holders++
+					if holders > maxHolders {
+						maxHolders = holders
+					}
+					mu.Unlock()
+
+					time.Sleep(time.Millisecond)
+					tv.Session = "1051689385"
+
+					mu.Lock()
+					holders--
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			So(maxHolders, ShouldEqual, 1)
+		})
+	})
+}
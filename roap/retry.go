@@ -0,0 +1,121 @@
+package roap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy decides how often and how patiently a request to a TV is tried
+// again after a transient failure
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, 1 means no retries
+	MaxAttempts int `json:"max_attempts"`
+	// Backoff is the pause before the first retry, doubled for every retry
+	// after it up to MaxBackoff
+	Backoff    Duration `json:"backoff"`
+	MaxBackoff Duration `json:"max_backoff"`
+	// Jitter randomly shortens or lengthens each pause by up to this
+	// fraction, e.g. 0.2, so a wall of TVs doesn't retry in lockstep
+	Jitter float64 `json:"jitter"`
+	// RetryUnsafe also retries requests a repeat could apply twice, i.e.
+	// showing the pairing key and toggle keys such as POWER, 3D and MUTE
+	RetryUnsafe bool `json:"retry_unsafe"`
+}
+
+// DefaultRetryPolicy is used by clients and TVs that don't set their own
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     Duration{200 * time.Millisecond},
+	MaxBackoff:  Duration{2 * time.Second},
+	Jitter:      0.2,
+}
+
+// backoff returns the pause before the given retry, counting from 1
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	d := p.Backoff.Duration
+	for i := 1; i < retry && (p.MaxBackoff.Duration <= 0 || d < p.MaxBackoff.Duration); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff.Duration > 0 && d > p.MaxBackoff.Duration {
+		d = p.MaxBackoff.Duration
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// IsRetryable reports whether err is a failure worth trying again: a refused
+// or dropped connection, a timeout or a 5xx answer from the TV
+func IsRetryable(err error) bool {
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) {
+		return false
+	}
+	if transportErr.StatusCode >= 500 {
+		return true
+	}
+	if transportErr.Err == nil {
+		return false
+	}
+
+	if errors.Is(transportErr.Err, syscall.ECONNREFUSED) ||
+		errors.Is(transportErr.Err, syscall.ECONNRESET) ||
+		errors.Is(transportErr.Err, io.EOF) ||
+		errors.Is(transportErr.Err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(transportErr.Err, &netErr) && netErr.Timeout()
+}
+
+// retryPolicy returns the retry policy for tv, its own or the client's
+func (c *Client) retryPolicy(tv *TV) RetryPolicy {
+	if tv.Retry != nil {
+		return *tv.Retry
+	}
+	return c.Retry
+}
+
+// do sends a request built by newRequest to the TV, trying again after
+// transient failures as the retry policy allows. Unsafe requests are only
+// tried again when the policy says so. A 5xx answer on the last attempt is
+// returned as it is for decodeResponse to report.
+func (c *Client) do(ctx context.Context, tv *TV, path string, unsafe bool, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	policy := c.retryPolicy(tv)
+	attempts := policy.MaxAttempts
+	if attempts < 1 || (unsafe && !policy.RetryUnsafe) {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, &TransportError{Path: path, Err: err}
+		}
+
+		resp, err := c.HTTPClient.Do(req.WithContext(ctx))
+		if err != nil {
+			err = &TransportError{Path: path, Err: err}
+		} else if resp.StatusCode >= 500 && attempt < attempts {
+			resp.Body.Close()
+			err = &TransportError{Path: path, StatusCode: resp.StatusCode}
+		} else {
+			return resp, nil
+		}
+
+		// the caller giving up is never worth a retry
+		if attempt >= attempts || ctx.Err() != nil || !IsRetryable(err) {
+			return nil, err
+		}
+		if err := sleep(ctx, policy.backoff(attempt)); err != nil {
+			return nil, &TransportError{Path: path, Err: err}
+		}
+	}
+}
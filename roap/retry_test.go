@@ -0,0 +1,130 @@
+package roap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const retrySuccess = `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail></envelope>`
+
+// failFirst fails the first n requests with err, or with status when err is
+// nil, and answers the rest with body
+func failFirst(n int, err error, status int, body string) (httpmock.Responder, *int) {
+	calls := 0
+	return func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls <= n {
+			if err != nil {
+				return nil, err
+			}
+			return httpmock.NewStringResponse(status, "busy"), nil
+		}
+		return httpmock.NewStringResponse(200, body), nil
+	}, &calls
+}
+
+func TestRetry(t *testing.T) {
+	refused := fmt.Errorf("dial tcp 192.168.1.100:8080: %w", syscall.ECONNREFUSED)
+	ctx := context.Background()
+
+	Convey("Given a client with a quick retry policy", t, func() {
+		client := NewClient()
+		client.Retry = RetryPolicy{MaxAttempts: 3, Backoff: Duration{time.Millisecond}}
+		tv := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123", Session: "1051689385"}
+
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		Convey("It should try a refused connection again", func() {
+			responder, calls := failFirst(2, refused, 0, is3DOn)
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", responder)
+
+			So(client.Check3D(ctx, tv), ShouldBeNil)
+			So(tv.Current3DState, ShouldEqual, "on")
+			So(*calls, ShouldEqual, 3)
+		})
+
+		Convey("It should try a 5xx answer again", func() {
+			responder, calls := failFirst(1, nil, 503, retrySuccess)
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", responder)
+
+			So(client.SendCommand(ctx, tv, "20"), ShouldBeNil)
+			So(*calls, ShouldEqual, 2)
+		})
+
+		Convey("It should give up after the last attempt", func() {
+			responder, calls := failFirst(5, nil, 500, retrySuccess)
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", responder)
+
+			err := client.SendCommand(ctx, tv, "20")
+			So(err, ShouldHaveSameTypeAs, &TransportError{})
+			So(err.(*TransportError).StatusCode, ShouldEqual, 500)
+			So(*calls, ShouldEqual, 3)
+		})
+
+		Convey("It should not retry errors that aren't transient", func() {
+			responder, calls := failFirst(1, fmt.Errorf("no route to host"), 0, retrySuccess)
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", responder)
+
+			So(client.SendCommand(ctx, tv, "20"), ShouldNotBeNil)
+			So(*calls, ShouldEqual, 1)
+		})
+
+		Convey("It should not repeat toggle keys or pairing unless allowed", func() {
+			command, commands := failFirst(3, refused, 0, retrySuccess)
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", command)
+			auth, auths := failFirst(1, refused, 0, retrySuccess)
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/auth", auth)
+
+			So(client.SendCommand(ctx, tv, "1"), ShouldNotBeNil)
+			So(*commands, ShouldEqual, 1)
+			So(client.SendCommand(ctx, tv, "400"), ShouldNotBeNil)
+			So(*commands, ShouldEqual, 2)
+			So(client.SendCommand(ctx, tv, "26"), ShouldNotBeNil)
+			So(*commands, ShouldEqual, 3)
+			So(client.DisplayPairingKey(ctx, tv), ShouldNotBeNil)
+			So(*auths, ShouldEqual, 1)
+
+			client.Retry.RetryUnsafe = true
+			So(client.SendCommand(ctx, tv, "1"), ShouldBeNil)
+			So(client.DisplayPairingKey(ctx, tv), ShouldBeNil)
+		})
+
+		Convey("It should use the TV's own retry policy", func() {
+			tv.Retry = &RetryPolicy{MaxAttempts: 1}
+			responder, calls := failFirst(1, refused, 0, is3DOn)
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", responder)
+
+			So(client.Check3D(ctx, tv), ShouldNotBeNil)
+			So(*calls, ShouldEqual, 1)
+		})
+
+		Convey("It should stop retrying when the context is done", func() {
+			client.Retry.Backoff = Duration{time.Second}
+			responder, calls := failFirst(5, refused, 0, is3DOn)
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", responder)
+
+			deadline, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			So(client.Check3D(deadline, tv), ShouldNotBeNil)
+			So(*calls, ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given a retry policy with backoff and jitter", t, func() {
+		policy := RetryPolicy{Backoff: Duration{100 * time.Millisecond}, MaxBackoff: Duration{time.Second}, Jitter: 0.1}
+
+		Convey("It should double the pause up to the maximum, give or take the jitter", func() {
+			So(policy.backoff(1), ShouldBeBetweenOrEqual, 90*time.Millisecond, 110*time.Millisecond)
+			So(policy.backoff(3), ShouldBeBetweenOrEqual, 360*time.Millisecond, 440*time.Millisecond)
+			So(policy.backoff(10), ShouldBeBetweenOrEqual, 900*time.Millisecond, 1100*time.Millisecond)
+		})
+	})
+}
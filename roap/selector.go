@@ -0,0 +1,107 @@
+package roap
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// Select returns the TVs matched by selector, in configuration order and
+// without repeats. A selector is a comma-separated list of terms, each of
+// which is one of
+//
+//	all         every TV
+//	TV-1        a TV name
+//	front-wall  a group name from the configuration
+//	tag:floor   every TV carrying the tag
+//	TV-*        a glob matched against TV names
+//
+// A term that matches nothing is an error, so typos never silently act on
+// fewer TVs than intended.
+func (r *Registry) Select(selector string) ([]*TV, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	selected := map[int]bool{}
+	for _, term := range strings.Split(selector, ",") {
+		if err := r.selectTerm(strings.TrimSpace(term), selected, map[string]bool{}); err != nil {
+			return nil, err
+		}
+	}
+
+	var tvs []*TV
+	for i, tv := range r.tvs {
+		if selected[i] {
+			tvs = append(tvs, tv)
+		}
+	}
+	return tvs, nil
+}
+
+// selectTerm marks the TVs matched by a single term. visited holds the groups
+// on the path to term, so a group containing itself can't loop forever while
+// a group reached through several others is fine.
+func (r *Registry) selectTerm(term string, selected map[int]bool, visited map[string]bool) error {
+	if term == "" {
+		return ErrNoSelector
+	}
+
+	matched := false
+	switch {
+	case term == "all":
+		for i := range r.tvs {
+			selected[i] = true
+			matched = true
+		}
+	case strings.HasPrefix(term, "tag:"):
+		tag := strings.TrimPrefix(term, "tag:")
+		for i, tv := range r.tvs {
+			if tv.HasTag(tag) {
+				selected[i] = true
+				matched = true
+			}
+		}
+	default:
+		for i, tv := range r.tvs {
+			if tv.Name == term {
+				selected[i] = true
+				matched = true
+			}
+		}
+		if matched {
+			break
+		}
+
+		if members, ok := r.groups[term]; ok {
+			if visited[term] {
+				return &SelectorError{Term: term, Err: errors.New("the group contains itself")}
+			}
+			visited[term] = true
+			defer delete(visited, term)
+			for _, member := range members {
+				if err := r.selectTerm(member, selected, visited); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if strings.ContainsAny(term, "*?[") {
+			for i, tv := range r.tvs {
+				ok, err := path.Match(term, tv.Name)
+				if err != nil {
+					return &SelectorError{Term: term, Err: err}
+				}
+				if ok {
+					selected[i] = true
+					matched = true
+				}
+			}
+		}
+	}
+
+	if !matched {
+		return &UnknownTVError{Name: term}
+	}
+	return nil
+}
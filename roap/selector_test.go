@@ -0,0 +1,119 @@
+package roap
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func names(tvs []*TV) []string {
+	var names []string
+	for _, tv := range tvs {
+		names = append(names, tv.Name)
+	}
+	return names
+}
+
+func TestSelect(t *testing.T) {
+	config := &TVConfig{
+		TVs: []TV{
+			{Name: "TV-1", IP: "192.168.1.100", Tags: []string{"floor"}},
+			{Name: "TV-2", IP: "192.168.1.101", Tags: []string{"wall", "left"}},
+			{Name: "TV-3", IP: "192.168.1.102", Tags: []string{"wall"}},
+			{Name: "Floor-1", IP: "192.168.1.110", Tags: []string{"floor"}},
+		},
+		Groups: map[string][]string{
+			"front-wall":  {"TV-2", "TV-3"},
+			"left-column": {"tag:left", "TV-1"},
+			"everything":  {"front-wall", "tag:floor"},
+			"loop":        {"TV-1", "loop"},
+			"core":        {"TV-1"},
+			"left":        {"core", "TV-2"},
+			"right":       {"core", "TV-3"},
+			"wall":        {"left", "right"},
+		},
+	}
+
+	registry := NewRegistry(config)
+
+	Convey("Given a TV configuration with groups and tags", t, func() {
+		Convey("It should select every TV with all", func() {
+			tvs, err := registry.Select("all")
+			So(err, ShouldBeNil)
+			So(names(tvs), ShouldResemble, []string{"TV-1", "TV-2", "TV-3", "Floor-1"})
+		})
+
+		Convey("It should select a single TV by name, pointing into the config", func() {
+			tvs, err := registry.Select("TV-2")
+			So(err, ShouldBeNil)
+			So(names(tvs), ShouldResemble, []string{"TV-2"})
+
+			tvs[0].Session = "1051689385"
+			So(config.TVs[1].Session, ShouldEqual, "1051689385")
+		})
+
+		Convey("It should select groups, including nested groups", func() {
+			tvs, err := registry.Select("front-wall")
+			So(err, ShouldBeNil)
+			So(names(tvs), ShouldResemble, []string{"TV-2", "TV-3"})
+
+			tvs, err = registry.Select("everything")
+			So(err, ShouldBeNil)
+			So(names(tvs), ShouldResemble, []string{"TV-1", "TV-2", "TV-3", "Floor-1"})
+		})
+
+		Convey("It should select tags", func() {
+			tvs, err := registry.Select("tag:floor")
+			So(err, ShouldBeNil)
+			So(names(tvs), ShouldResemble, []string{"TV-1", "Floor-1"})
+		})
+
+		Convey("It should select lists and globs without repeats, in config order", func() {
+			tvs, err := registry.Select("TV-3, TV-1,front-wall")
+			So(err, ShouldBeNil)
+			So(names(tvs), ShouldResemble, []string{"TV-1", "TV-2", "TV-3"})
+
+			tvs, err = registry.Select("TV-*")
+			So(err, ShouldBeNil)
+			So(names(tvs), ShouldResemble, []string{"TV-1", "TV-2", "TV-3"})
+		})
+
+		Convey("It should reject anything that matches no TV", func() {
+			_, err := registry.Select("TV-9")
+			So(err, ShouldHaveSameTypeAs, &UnknownTVError{})
+
+			_, err = registry.Select("TV-1,TV-9")
+			So(err, ShouldHaveSameTypeAs, &UnknownTVError{})
+
+			_, err = registry.Select("tag:ceiling")
+			So(err, ShouldHaveSameTypeAs, &UnknownTVError{})
+
+			_, err = registry.Select("Ceiling-*")
+			So(err, ShouldHaveSameTypeAs, &UnknownTVError{})
+		})
+
+		Convey("It should say so when no TVs are named", func() {
+			_, err := registry.Select("")
+			So(err, ShouldEqual, ErrNoSelector)
+
+			_, err = registry.Select("TV-1,")
+			So(err, ShouldEqual, ErrNoSelector)
+		})
+
+		Convey("It should select a group reached through several others", func() {
+			tvs, err := registry.Select("wall")
+			So(err, ShouldBeNil)
+			So(names(tvs), ShouldResemble, []string{"TV-1", "TV-2", "TV-3"})
+		})
+
+		Convey("It should refuse groups that contain themselves", func() {
+			_, err := registry.Select("loop")
+			So(err, ShouldHaveSameTypeAs, &SelectorError{})
+		})
+
+		Convey("It should refuse malformed patterns", func() {
+			_, err := registry.Select("TV-[")
+			So(err, ShouldHaveSameTypeAs, &SelectorError{})
+		})
+	})
+}
@@ -0,0 +1,124 @@
+package roap
+
+import (
+	"context"
+	"time"
+)
+
+// Transition records what a 3D state change did on one TV
+type Transition struct {
+	// Cached is the state the client believed before asking the TV, if any
+	Cached string
+	// Before and After are the states reported by the TV itself
+	Before string
+	After  string
+	// Changed is true when keys had to be sent to reach the state
+	Changed bool
+}
+
+// Drifted reports whether the cached state disagreed with the TV
+func (t *Transition) Drifted() bool {
+	return t.Cached != "" && t.Before != "" && t.Cached != t.Before
+}
+
+// Enable3D enables 3D mode if TV not in 3D mode, see Set3D
+func (c *Client) Enable3D(ctx context.Context, tv *TV) error {
+	_, err := c.Set3D(ctx, tv, "on")
+	return err
+}
+
+// Disable3D disables 3D mode if currently in 3D, see Set3D
+func (c *Client) Disable3D(ctx context.Context, tv *TV) error {
+	_, err := c.Set3D(ctx, tv, "off")
+	return err
+}
+
+// Set3D brings the 3D state of the TV to want, "on" or "off". The state is
+// read from the TV first and keys are only sent when it differs, so calling
+// Set3D again is harmless. Afterwards the TV is polled until it reports the
+// new state or StateTimeout passes; while enabling, the confirmation is sent
+// again up to Enable3DRetries times.
+func (c *Client) Set3D(ctx context.Context, tv *TV, want string) (*Transition, error) {
+	transition, err := c.Plan3D(ctx, tv)
+	if err != nil {
+		return transition, err
+	}
+	return transition, c.Apply3D(ctx, tv, transition, want)
+}
+
+// Plan3D reads the current 3D state of the TV, the first half of Set3D
+func (c *Client) Plan3D(ctx context.Context, tv *TV) (*Transition, error) {
+	c.restore(tv)
+	transition := &Transition{Cached: tv.Current3DState}
+
+	if err := c.Check3D(ctx, tv); err != nil {
+		return transition, err
+	}
+	transition.Before = tv.Current3DState
+	transition.After = tv.Current3DState
+	return transition, nil
+}
+
+// Apply3D sends the keys to go from the state found by Plan3D to want and
+// waits for the TV to confirm, the second half of Set3D. Nothing is sent
+// unless the TV reported "on" or "off".
+func (c *Client) Apply3D(ctx context.Context, tv *TV, transition *Transition, want string) error {
+	if transition.Before == want {
+		return nil
+	}
+	if transition.Before != "on" && transition.Before != "off" {
+		return &UnknownStateError{State: transition.Before}
+	}
+
+	delay := c.Enable3DDelay
+	if tv.Enable3DDelay.Duration > 0 {
+		delay = tv.Enable3DDelay.Duration
+	}
+
+	// The 3D key toggles the mode; switching on also needs the choice in the
+	// 3D menu confirmed, as the enable-3D macro does
+	tokens := []string{"3D"}
+	if want == "on" {
+		var err error
+		if tokens, err = lookupMacro(c.Macros, "enable-3D"); err != nil {
+			return err
+		}
+	}
+	steps, err := ParseSequence(tokens, delay)
+	if err != nil {
+		return err
+	}
+	if err := c.SendSequence(ctx, tv, steps); err != nil {
+		return err
+	}
+	transition.Changed = true
+
+	pollInterval := delay
+	if pollInterval <= 0 {
+		pollInterval = DefaultEnable3DDelay
+	}
+	deadline := time.Now().Add(c.StateTimeout)
+	retries := 0
+	for {
+		if err := sleep(ctx, pollInterval); err != nil {
+			return err
+		}
+		if err := c.Check3D(ctx, tv); err != nil {
+			return err
+		}
+		transition.After = tv.Current3DState
+		if transition.After == want {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return &StateError{Want: want, Got: transition.After}
+		}
+
+		if want == "on" && retries < c.Enable3DRetries {
+			retries++
+			if err := c.SendSequence(ctx, tv, steps[len(steps)-1:]); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package roap
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Status is a health report of one TV
+type Status struct {
+	// Reachable is true when the TV accepted a TCP connection on Port, and
+	// Latency is how long that took
+	Reachable bool
+	Latency   time.Duration
+	// Authorized is true when the pairing key got a session
+	Authorized bool
+	State3D    string
+	// Channel and Volume are nil when the TV wouldn't say, e.g. while it
+	// shows an app
+	Channel *Channel
+	Volume  *VolumeInfo
+}
+
+// Status checks that the TV is reachable and authorizes its pairing key,
+// then reads its 3D state, channel and volume. The error is that of the
+// first check that failed; a TV that can't report its channel or volume is
+// still healthy.
+func (c *Client) Status(ctx context.Context, tv *TV) (*Status, error) {
+	status := &Status{}
+
+	dial := c.dial
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: c.ConnectTimeout}).DialContext
+	}
+	start := time.Now()
+	conn, err := dial(ctx, "tcp", net.JoinHostPort(tv.IP, Port))
+	if err != nil {
+		return status, &TransportError{Path: "tcp", Err: err}
+	}
+	status.Latency = time.Since(start)
+	status.Reachable = true
+	conn.Close()
+
+	tv.Session = ""
+	if err := c.GetTVSession(ctx, tv); err != nil {
+		return status, err
+	}
+	status.Authorized = true
+
+	err = c.Check3D(ctx, tv)
+	status.State3D = tv.Current3DState
+	if err != nil {
+		return status, err
+	}
+
+	status.Channel, _ = c.CurrentChannel(ctx, tv)
+	status.Volume, _ = c.Volume(ctx, tv)
+	return status, nil
+}
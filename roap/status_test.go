@@ -0,0 +1,86 @@
+package roap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const curChannel = `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><dataList name="Channel Info"><data><chtype>terrestrial</chtype><sourceIndex>1</sourceIndex><physicalNum>22</physicalNum><major>11</major><displayMajor>11</displayMajor><minor>1</minor><displayMinor>1</displayMinor><chname>KQED-HD</chname><progName>News</progName><audioCh>0</audioCh><inputSourceName>TV</inputSourceName><inputSourceType>0</inputSourceType><labelName></labelName><inputSourceIdx>0</inputSourceIdx></data></dataList></envelope>`
+
+const volumeInfo = `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><dataList name="Volume Info"><data><mute>false</mute><minLevel>0</minLevel><maxLevel>100</maxLevel><level>12</level></data></dataList></envelope>`
+
+// pipeDial pretends every TV accepts connections
+func pipeDial(ctx context.Context, network, address string) (net.Conn, error) {
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+func TestStatus(t *testing.T) {
+	Convey("Given a paired TV", t, func() {
+		client := NewClient()
+		client.dial = pipeDial
+		tv := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123"}
+		ctx := context.Background()
+
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/auth", httpmock.NewStringResponder(200, `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><session>1051689385</session></envelope>`))
+		httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=is_3d", httpmock.NewStringResponder(200, is3DOn))
+
+		Convey("It should report its 3D state, channel and volume", func() {
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=cur_channel", httpmock.NewStringResponder(200, curChannel))
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=volume_info", httpmock.NewStringResponder(200, volumeInfo))
+
+			status, err := client.Status(ctx, tv)
+			So(err, ShouldBeNil)
+			So(status.Reachable, ShouldBeTrue)
+			So(status.Authorized, ShouldBeTrue)
+			So(status.State3D, ShouldEqual, "on")
+			So(status.Channel.Number(), ShouldEqual, "11-1")
+			So(status.Channel.Name, ShouldEqual, "KQED-HD")
+			So(status.Channel.InputSourceName, ShouldEqual, "TV")
+			So(status.Volume.Level, ShouldEqual, 12)
+			So(status.Volume.Mute, ShouldBeFalse)
+		})
+
+		Convey("It should still be healthy without a channel or volume", func() {
+			unsupported := `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>404</ROAPError><ROAPErrorDetail>Not Found</ROAPErrorDetail></envelope>`
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=cur_channel", httpmock.NewStringResponder(200, unsupported))
+			httpmock.RegisterResponder("GET", "http://192.168.1.100:8080/roap/api/data?target=volume_info", httpmock.NewStringResponder(200, unsupported))
+
+			status, err := client.Status(ctx, tv)
+			So(err, ShouldBeNil)
+			So(status.Channel, ShouldBeNil)
+			So(status.Volume, ShouldBeNil)
+		})
+
+		Convey("It should report a pairing key the TV refuses", func() {
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/auth", httpmock.NewStringResponder(200, `<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>401</ROAPError><ROAPErrorDetail>Unauthorized</ROAPErrorDetail></envelope>`))
+
+			status, err := client.Status(ctx, tv)
+			So(IsUnauthorized(err), ShouldBeTrue)
+			So(status.Reachable, ShouldBeTrue)
+			So(status.Authorized, ShouldBeFalse)
+		})
+
+		Convey("It should report a TV that refuses connections", func() {
+			client.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+				So(address, ShouldEqual, "192.168.1.100:8080")
+				return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+			}
+
+			status, err := client.Status(ctx, tv)
+			So(err, ShouldHaveSameTypeAs, &TransportError{})
+			So(errors.Is(err, syscall.ECONNREFUSED), ShouldBeTrue)
+			So(status.Reachable, ShouldBeFalse)
+			So(httpmock.GetTotalCallCount(), ShouldEqual, 0)
+		})
+	})
+}
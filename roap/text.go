@@ -0,0 +1,22 @@
+package roap
+
+import (
+	"context"
+	"fmt"
+)
+
+// TypeText fills the text field the TV's on-screen keyboard is open on with
+// text, using HandleKeyboardInput. The text is escaped, so URLs and search
+// terms with &, < or quotes arrive as typed.
+func (c *Client) TypeText(ctx context.Context, tv *TV, text string) error {
+	if text == "" {
+		return ErrNoText
+	}
+
+	commandBody := fmt.Sprintf(`<!--?xml version="1.0" encoding="utf-8"?--><command><name>HandleKeyboardInput</name><value>%s</value></command>`, xmlEscape(text))
+	return c.withSession(ctx, tv, func() error {
+		// some TVs append to the field rather than replace it, so a repeat
+		// could leave the text in it twice
+		return c.postCommand(ctx, tv, commandBody, true)
+	})
+}
@@ -0,0 +1,56 @@
+package roap
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTypeText(t *testing.T) {
+	Convey("Given a TV with its on-screen keyboard open", t, func() {
+		client := NewClient()
+		tv := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123", Session: "1051689385"}
+		ctx := context.Background()
+
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		var commands []string
+		httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", func(req *http.Request) (*http.Response, error) {
+			body, _ := ioutil.ReadAll(req.Body)
+			commands = append(commands, string(body))
+			return httpmock.NewStringResponse(200, retrySuccess), nil
+		})
+
+		Convey("It should send the text with HandleKeyboardInput", func() {
+			err := client.TypeText(ctx, tv, "cave viewer")
+			So(err, ShouldBeNil)
+			So(commands, ShouldHaveLength, 1)
+			So(commands[0], ShouldContainSubstring, "<command><name>HandleKeyboardInput</name><value>cave viewer</value></command>")
+		})
+
+		Convey("It should escape the text", func() {
+			err := client.TypeText(ctx, tv, `http://example.com/?a=1&b=<2> "x"`)
+			So(err, ShouldBeNil)
+			So(commands[0], ShouldContainSubstring, "<value>http://example.com/?a=1&amp;b=&lt;2&gt; &#34;x&#34;</value>")
+		})
+
+		Convey("It should refuse empty text", func() {
+			err := client.TypeText(ctx, tv, "")
+			So(err, ShouldEqual, ErrNoText)
+			So(commands, ShouldBeEmpty)
+		})
+
+		Convey("It should not repeat the text after a failure", func() {
+			httpmock.RegisterResponder("POST", "http://192.168.1.100:8080/roap/api/command", httpmock.NewStringResponder(503, ""))
+
+			err := client.TypeText(ctx, tv, "cave viewer")
+			So(err, ShouldNotBeNil)
+			So(httpmock.GetCallCountInfo()["POST http://192.168.1.100:8080/roap/api/command"], ShouldEqual, 1)
+		})
+	})
+}
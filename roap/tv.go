@@ -0,0 +1,53 @@
+// Package roap is a client for the ROAP HTTP API exposed by LG Smart TVs.
+//
+// It handles pairing, session authorization, key commands and 3D state
+// queries, and is shared by the lg_remote command line tool.
+package roap
+
+// Port is the default port for the LG TV API
+const Port string = "8080"
+
+// BaseURI is the default base URI for the LG TV API
+const BaseURI string = "/roap/api"
+
+// TV record from JSON configuration file
+type TV struct {
+	Name           string `json:"name"`
+	IP             string `json:"ip"`
+	Key            string `json:"key"`
+	Current3DState string
+	Session        string
+	// Enable3DDelay overrides the client's pause before confirming 3D
+	Enable3DDelay Duration `json:"enable_3d_delay"`
+	// Tags can be used to select TVs, e.g. tag:floor
+	Tags []string `json:"tags"`
+	// Retry overrides the client's retry policy as a whole
+	Retry *RetryPolicy `json:"retry"`
+}
+
+// HasTag reports whether the TV carries tag
+func (tv *TV) HasTag(tag string) bool {
+	for _, t := range tv.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildURI returns the complete URI string
+func BuildURI(tv *TV, path string) string {
+	uri := "http://" + tv.IP + ":" + Port + BaseURI + path
+	return uri
+}
+
+// FindTvByName will return a TV from the TVConfig collection. The TV returned
+// is the element of tvs itself, so changes to it are kept in the collection.
+func FindTvByName(name string, tvs []TV) (*TV, error) {
+	for i := range tvs {
+		if tvs[i].Name == name {
+			return &tvs[i], nil
+		}
+	}
+	return nil, &UnknownTVError{Name: name}
+}
@@ -0,0 +1,130 @@
+package roap
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultVolumeKeyDelay is the pause between volume key presses, quicker
+// presses are dropped by some TVs
+const DefaultVolumeKeyDelay = 150 * time.Millisecond
+
+// volumeRounds is how many times SetVolume presses keys and reads the volume
+// back before giving up on reaching the level
+const volumeRounds = 3
+
+// VolumeError is returned when a TV doesn't reach the requested volume
+type VolumeError struct {
+	Want int
+	Got  int
+}
+
+func (e *VolumeError) Error() string {
+	return fmt.Sprintf("roap: TV did not reach volume %d, it reports %d", e.Want, e.Got)
+}
+
+// VolumeChange records what a volume change did on one TV
+type VolumeChange struct {
+	Before int
+	After  int
+	// Want is the level the change aimed for
+	Want int
+}
+
+// SetVolume brings the volume of the TV to level. The ROAP API has no
+// absolute volume command, so it reads volume_info, presses VOL_UP or
+// VOL_DOWN as many times as the difference and reads the volume back,
+// pressing again for any presses the TV dropped.
+func (c *Client) SetVolume(ctx context.Context, tv *TV, level int) (*VolumeChange, error) {
+	volume, err := c.Volume(ctx, tv)
+	if err != nil {
+		return nil, err
+	}
+	if level < volume.Min || level > volume.Max {
+		return nil, fmt.Errorf("roap: volume %d is out of range, %s takes %d to %d", level, tv.Name, volume.Min, volume.Max)
+	}
+	return c.stepVolume(ctx, tv, volume, level)
+}
+
+// AdjustVolume turns the volume of the TV up, or down for a negative delta,
+// by delta levels, stopping at the ends of its range
+func (c *Client) AdjustVolume(ctx context.Context, tv *TV, delta int) (*VolumeChange, error) {
+	volume, err := c.Volume(ctx, tv)
+	if err != nil {
+		return nil, err
+	}
+	level := volume.Level + delta
+	if level < volume.Min {
+		level = volume.Min
+	}
+	if level > volume.Max {
+		level = volume.Max
+	}
+	return c.stepVolume(ctx, tv, volume, level)
+}
+
+// stepVolume presses the volume keys until the TV, now at volume, reports level
+func (c *Client) stepVolume(ctx context.Context, tv *TV, volume *VolumeInfo, level int) (*VolumeChange, error) {
+	change := &VolumeChange{Before: volume.Level, After: volume.Level, Want: level}
+	for round := 0; change.After != level; round++ {
+		if round == volumeRounds {
+			return change, &VolumeError{Want: level, Got: change.After}
+		}
+
+		code, presses := volumeUpCode, level-change.After
+		if presses < 0 {
+			code, presses = volumeDownCode, -presses
+		}
+		steps := make([]Step, presses)
+		for i := range steps {
+			steps[i] = Step{Code: code, Delay: c.VolumeKeyDelay}
+		}
+		if err := c.SendSequence(ctx, tv, steps); err != nil {
+			return change, err
+		}
+		if err := sleep(ctx, c.VolumeKeyDelay); err != nil {
+			return change, err
+		}
+
+		volume, err := c.Volume(ctx, tv)
+		if err != nil {
+			return change, err
+		}
+		change.After = volume.Level
+	}
+	return change, nil
+}
+
+// SetMute mutes or unmutes the TV with the MUTE key, which toggles, so the
+// key is only pressed when the TV isn't muted as wanted. It reports whether
+// the TV was muted before.
+func (c *Client) SetMute(ctx context.Context, tv *TV, mute bool) (bool, error) {
+	volume, err := c.Volume(ctx, tv)
+	if err != nil {
+		return false, err
+	}
+	if volume.Mute == mute {
+		return volume.Mute, nil
+	}
+
+	if err := c.SendCommand(ctx, tv, muteCode); err != nil {
+		return volume.Mute, err
+	}
+	if err := sleep(ctx, c.VolumeKeyDelay); err != nil {
+		return volume.Mute, err
+	}
+
+	after, err := c.Volume(ctx, tv)
+	if err != nil {
+		return volume.Mute, err
+	}
+	if after.Mute != mute {
+		action := "unmute"
+		if mute {
+			action = "mute"
+		}
+		return volume.Mute, fmt.Errorf("roap: %s did not %s", tv.Name, action)
+	}
+	return volume.Mute, nil
+}
@@ -0,0 +1,163 @@
+package roap
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeVolume is a TV's volume that follows the volume keys sent to it
+type fakeVolume struct {
+	mu      sync.Mutex
+	level   int
+	mute    bool
+	presses int
+	// drop ignores this many volume key presses, as a busy TV does
+	drop int
+}
+
+var keyValue = regexp.MustCompile(`<value>(\d+)</value>`)
+
+func (f *fakeVolume) register(ip string) {
+	httpmock.RegisterResponder("POST", "http://"+ip+":8080/roap/api/command", func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		key := keyValue.FindStringSubmatch(string(body))[1]
+		f.presses++
+		if f.drop > 0 && key != muteCode {
+			f.drop--
+		} else {
+			switch key {
+			case volumeUpCode:
+				f.level++
+			case volumeDownCode:
+				f.level--
+			case muteCode:
+				f.mute = !f.mute
+			}
+		}
+		return httpmock.NewStringResponse(200, retrySuccess), nil
+	})
+	httpmock.RegisterResponder("GET", "http://"+ip+":8080/roap/api/data?target=volume_info", func(req *http.Request) (*http.Response, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return httpmock.NewStringResponse(200, fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?><envelope><ROAPError>200</ROAPError><ROAPErrorDetail>OK</ROAPErrorDetail><dataList name="Volume Info"><data><mute>%t</mute><minLevel>0</minLevel><maxLevel>100</maxLevel><level>%d</level></data></dataList></envelope>`, f.mute, f.level)), nil
+	})
+}
+
+func TestVolume(t *testing.T) {
+	Convey("Given a TV at volume 8", t, func() {
+		client := NewClient()
+		client.VolumeKeyDelay = 0
+		tv := &TV{Name: "TV-1", IP: "192.168.1.100", Key: "xyz123", Session: "1051689385"}
+		ctx := context.Background()
+
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		volume := &fakeVolume{level: 8}
+		volume.register(tv.IP)
+
+		Convey("It should press VOL_UP as many times as needed", func() {
+			change, err := client.SetVolume(ctx, tv, 12)
+			So(err, ShouldBeNil)
+			So(*change, ShouldResemble, VolumeChange{Before: 8, After: 12, Want: 12})
+			So(volume.presses, ShouldEqual, 4)
+		})
+
+		Convey("It should press VOL_DOWN to turn it down", func() {
+			change, err := client.SetVolume(ctx, tv, 3)
+			So(err, ShouldBeNil)
+			So(change.After, ShouldEqual, 3)
+			So(volume.presses, ShouldEqual, 5)
+		})
+
+		Convey("It should press nothing when the level is right", func() {
+			_, err := client.SetVolume(ctx, tv, 8)
+			So(err, ShouldBeNil)
+			So(volume.presses, ShouldEqual, 0)
+		})
+
+		Convey("It should make up for dropped presses", func() {
+			volume.drop = 2
+			change, err := client.SetVolume(ctx, tv, 12)
+			So(err, ShouldBeNil)
+			So(change.After, ShouldEqual, 12)
+			So(volume.presses, ShouldEqual, 6)
+		})
+
+		Convey("It should give up when the TV ignores the keys", func() {
+			volume.drop = 1000
+			change, err := client.SetVolume(ctx, tv, 12)
+			So(err, ShouldHaveSameTypeAs, &VolumeError{})
+			So(change.After, ShouldEqual, 8)
+		})
+
+		Convey("It should refuse a level outside the TV's range", func() {
+			_, err := client.SetVolume(ctx, tv, 101)
+			So(err, ShouldNotBeNil)
+			So(volume.presses, ShouldEqual, 0)
+		})
+
+		Convey("It should turn up and down by steps within the range", func() {
+			change, err := client.AdjustVolume(ctx, tv, 2)
+			So(err, ShouldBeNil)
+			So(change.After, ShouldEqual, 10)
+			So(change.Want, ShouldEqual, 10)
+
+			change, err = client.AdjustVolume(ctx, tv, -20)
+			So(err, ShouldBeNil)
+			So(change.After, ShouldEqual, 0)
+			So(change.Want, ShouldEqual, 0)
+		})
+
+		Convey("It should only press MUTE when it changes something", func() {
+			was, err := client.SetMute(ctx, tv, true)
+			So(err, ShouldBeNil)
+			So(was, ShouldBeFalse)
+			So(volume.mute, ShouldBeTrue)
+
+			was, err = client.SetMute(ctx, tv, true)
+			So(err, ShouldBeNil)
+			So(was, ShouldBeTrue)
+			So(volume.presses, ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given TVs at different volumes", t, func() {
+		client := NewClient()
+		client.VolumeKeyDelay = 0
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		config := &TVConfig{TVs: []TV{
+			{Name: "TV-1", IP: "192.168.1.100", Session: "1"},
+			{Name: "TV-2", IP: "192.168.1.101", Session: "2"},
+			{Name: "TV-3", IP: "192.168.1.102", Session: "3"},
+		}}
+		volumes := []*fakeVolume{{level: 3}, {level: 30, drop: 4}, {level: 12}}
+		for i, tv := range config.TVs {
+			volumes[i].register(tv.IP)
+		}
+
+		Convey("Setting them all should converge on one level", func() {
+			cluster := &Cluster{}
+			results := cluster.Run(context.Background(), NewRegistry(config).TVs(), func(ctx context.Context, tv *TV) (string, error) {
+				_, err := client.SetVolume(ctx, tv, 12)
+				return "", err
+			})
+
+			So(Failed(results), ShouldBeEmpty)
+			for _, volume := range volumes {
+				So(volume.level, ShouldEqual, 12)
+			}
+		})
+	})
+}